@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/Lucascluz/reverxy/internal/config"
+	"github.com/Lucascluz/reverxy/internal/loadbalancer/discovery"
 	"github.com/Lucascluz/reverxy/internal/observability"
 	"github.com/Lucascluz/reverxy/internal/proxy"
 )
@@ -49,6 +50,8 @@ type app struct {
 	config         *config.Config
 	observability  *observability.Observability
 	proxy          *proxy.Proxy
+	warmer         *proxy.Warmer
+	discovery      *discovery.Discovery
 	proxySrv       *http.Server
 	probeSrv       *http.Server
 	shutdownSignal chan os.Signal
@@ -81,6 +84,14 @@ func initialize(logger *log.Logger) (*app, error) {
 	// Get proxy instance
 	p := setup.Proxy()
 
+	// Restore cached responses persisted by the previous instance
+	restored, err := p.RestoreCache()
+	if err != nil {
+		logger.Printf("cache snapshot not restored: %v", err)
+	} else if restored > 0 {
+		logger.Printf("restored %d cache entries from snapshot", restored)
+	}
+
 	// Build the complete handler with middleware
 	handler, err := setup.Handler()
 	if err != nil {
@@ -88,10 +99,23 @@ func initialize(logger *log.Logger) (*app, error) {
 	}
 	logger.Println("proxy handler configured")
 
-	// Get backends from load balancer for health checking
-	backendInterfaces := make([]observability.HealthAware, 0)
-	for _, b := range p.LoadBalancer().Pool().Backends() {
-		backendInterfaces = append(backendInterfaces, b)
+	// Discover dynamic backends before the first health checks run
+	disc, err := discovery.New(&cfg.LoadBalancer.Pool, p.LoadBalancer().Pool())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery: %w", err)
+	}
+	if disc != nil {
+		disc.Start()
+		logger.Printf("%s discovery started", cfg.LoadBalancer.Pool.Discovery.Type)
+	}
+
+	// Get backends from load balancer for health checking, following pool changes
+	backendInterfaces := func() []observability.HealthAware {
+		backends := make([]observability.HealthAware, 0)
+		for _, b := range p.LoadBalancer().Pool().Backends() {
+			backends = append(backends, b)
+		}
+		return backends
 	}
 
 	// Create observability hub
@@ -112,12 +136,14 @@ func initialize(logger *log.Logger) (*app, error) {
 
 	// Setup proxy servers
 	proxySrv := createProxyServer(cfg, handler)
-	probeSrv := createProbeServer(cfg, obs.Probe())
+	probeSrv := createProbeServer(cfg, obs.Probe(), setup.AdminHandler())
 
 	app := &app{
 		config:         cfg,
 		observability:  obs,
 		proxy:          p,
+		warmer:         setup.Warmer(),
+		discovery:      disc,
 		proxySrv:       proxySrv,
 		probeSrv:       probeSrv,
 		shutdownSignal: make(chan os.Signal, 1),
@@ -156,8 +182,14 @@ func (a *app) start(logger *log.Logger) error {
 		return err
 	case <-time.After(100 * time.Millisecond):
 		// Give servers time to start; if they crash, we'll catch it on shutdown
-		return nil
 	}
+
+	// Populate the cache in the background once a backend is healthy
+	if a.config.Cache.Warmup.OnStartup {
+		go a.warmer.StartOnReady()
+	}
+
+	return nil
 }
 
 // waitForShutdown blocks until a shutdown signal is received
@@ -181,7 +213,14 @@ func (a *app) shutdown(logger *log.Logger) error {
 	logger.Println("marking proxy as not ready (draining connections)")
 	a.proxy.SetReady(false)
 
-	// Step 2: Stop observability components (health checker)
+	// Step 2: Stop observability components (health checker), backend discovery
+	// and cache warm-up
+	a.warmer.Stop()
+
+	if a.discovery != nil {
+		a.discovery.Stop()
+	}
+
 	logger.Println("stopping observability components")
 	if err := a.observability.Stop(); err != nil {
 		logger.Printf("error stopping observability: %v", err)
@@ -229,6 +268,14 @@ func (a *app) shutdown(logger *log.Logger) error {
 		}
 	}
 
+	// Step 7: Persist the cache once no request can modify it anymore
+	saved, err := a.proxy.Close()
+	if err != nil {
+		logger.Printf("error closing proxy cache: %v", err)
+	} else if saved > 0 {
+		logger.Printf("saved %d cache entries to snapshot", saved)
+	}
+
 	return nil
 }
 
@@ -243,11 +290,16 @@ func createProxyServer(cfg *config.Config, handler http.Handler) *http.Server {
 	}
 }
 
-// createProbeServer configures the probe/health check HTTP server
-func createProbeServer(cfg *config.Config, probe *observability.Probe) *http.Server {
+// createProbeServer configures the probe/health check HTTP server, which also
+// hosts the admin API under /admin/
+func createProbeServer(cfg *config.Config, probe *observability.Probe, admin http.Handler) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/", probe.Handler())
+	mux.Handle("/admin/", admin)
+
 	return &http.Server{
 		Addr:         ":" + cfg.Proxy.ProbePort,
-		Handler:      probe.Handler(),
+		Handler:      mux,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 5 * time.Second,
 		IdleTimeout:  5 * time.Second,
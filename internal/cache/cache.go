@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"errors"
 	"time"
 
 	"github.com/Lucascluz/reverxy/internal/config"
@@ -12,10 +13,31 @@ type Cache interface {
 	Delete(key string)
 	Exists(key string) bool
 	Stop() error
+
+	// Tag associates an existing key with one or more tags (surrogate keys)
+	// so it can later be purged as part of a group.
+	Tag(key string, tags ...string)
+
+	// PurgeTag deletes every entry associated with tag and returns how many
+	// entries were removed.
+	PurgeTag(tag string) int
+
+	// PurgePrefix deletes every entry whose key starts with prefix and returns
+	// how many entries were removed. An error means the purge did not complete,
+	// or ErrPrefixPurgeUnsupported that the backend cannot purge by prefix.
+	PurgePrefix(prefix string) (int, error)
 }
 
-func NewCache(cfg *config.CacheConfig) Cache {
+// ErrPrefixPurgeUnsupported is returned by caches that cannot enumerate their keys
+var ErrPrefixPurgeUnsupported = errors.New("prefix purge is not supported by this cache")
 
-	//TODO: Implement various cache options (redis, memcached, etc.)
-	return NewInMemoryCache(cfg)
+func NewCache(cfg *config.CacheConfig) Cache {
+	switch cfg.Type {
+	case "redis":
+		return NewRedisCache(cfg)
+	case "memcached":
+		return NewMemcachedCache(cfg)
+	default:
+		return NewInMemoryCache(cfg)
+	}
 }
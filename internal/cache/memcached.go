@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Memcached rejects expirations above 30 days as relative values and
+// interprets them as absolute unix timestamps instead.
+const memcachedMaxRelativeTTL = 30 * 24 * time.Hour
+
+// Tag indexes only grow between purges, and Memcached rejects items above 1MB
+// by default. After memcachedTagPruneBytes of appends an index is rewritten
+// without the keys that have since expired; if it still exceeds
+// memcachedTagIndexLimit, its oldest keys are evicted from the cache so a
+// later purge cannot miss them.
+const (
+	memcachedTagPruneBytes = 64 << 10
+	memcachedTagIndexLimit = 512 << 10
+	memcachedGetBatch      = 100 // Keys looked up per multi-get while pruning
+)
+
+// memcachedCache stores entries in one or more Memcached servers shared by every
+// reverxy replica. Like redisCache, errors and timeouts degrade to cache misses.
+type memcachedCache struct {
+	client *memcache.Client
+	prefix string
+
+	mu       sync.Mutex
+	appended map[string]int // Bytes appended to each tag index since its last prune
+}
+
+func NewMemcachedCache(cfg *config.CacheConfig) *memcachedCache {
+	client := memcache.New(cfg.Memcached.Servers...)
+	client.Timeout = cfg.Memcached.Timeout
+	client.MaxIdleConns = cfg.Memcached.MaxIdleConns
+
+	return &memcachedCache{
+		client:   client,
+		prefix:   cfg.Memcached.KeyPrefix,
+		appended: make(map[string]int),
+	}
+}
+
+// hashKey maps a cache key onto a Memcached-safe key. Memcached keys are limited
+// to 250 bytes without spaces or control characters, which request URIs do not
+// guarantee.
+func (c *memcachedCache) hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return c.prefix + hex.EncodeToString(sum[:])
+}
+
+func (c *memcachedCache) Set(key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.client.Set(&memcache.Item{
+		Key:        c.hashKey(key),
+		Value:      value,
+		Expiration: memcachedExpiration(ttl),
+	})
+}
+
+func (c *memcachedCache) Get(key string) ([]byte, bool) {
+	item, err := c.client.Get(c.hashKey(key))
+	if err != nil {
+		return nil, false
+	}
+
+	return item.Value, true
+}
+
+func (c *memcachedCache) Delete(key string) {
+	c.client.Delete(c.hashKey(key))
+}
+
+func (c *memcachedCache) Exists(key string) bool {
+	_, found := c.Get(key)
+	return found
+}
+
+// Tag appends key to an index item per tag. Memcached has no sets, so the index
+// is a newline-separated list that is dropped when the tag is purged.
+func (c *memcachedCache) Tag(key string, tags ...string) {
+	for _, tag := range tags {
+		item := &memcache.Item{
+			Key:        c.hashKey("tags:" + tag),
+			Value:      []byte(key + "\n"),
+			Expiration: memcachedExpiration(memcachedMaxRelativeTTL),
+		}
+
+		err := c.client.Append(item)
+		if errors.Is(err, memcache.ErrNotStored) {
+			// First key for this tag; retry the append if another replica won the race
+			if err := c.client.Add(item); errors.Is(err, memcache.ErrNotStored) {
+				c.client.Append(item)
+			}
+		}
+
+		c.mu.Lock()
+		c.appended[tag] += len(item.Value)
+		prune := c.appended[tag] >= memcachedTagPruneBytes
+		if prune {
+			delete(c.appended, tag)
+		}
+		c.mu.Unlock()
+
+		if prune {
+			c.pruneTag(tag)
+		}
+	}
+}
+
+// pruneTag rewrites the index of tag without duplicate and expired keys. The
+// rewrite is skipped if the index changed meanwhile; a later prune retries.
+func (c *memcachedCache) pruneTag(tag string) {
+	item, err := c.client.Get(c.hashKey("tags:" + tag))
+	if err != nil {
+		return
+	}
+
+	keys := uniqueLines(item.Value)
+	hashed := make([]string, len(keys))
+	for i, key := range keys {
+		hashed[i] = c.hashKey(key)
+	}
+
+	live := make(map[string]*memcache.Item, len(hashed))
+	for start := 0; start < len(hashed); start += memcachedGetBatch {
+		items, err := c.client.GetMulti(hashed[start:min(start+memcachedGetBatch, len(hashed))])
+		if err != nil {
+			return
+		}
+		maps.Copy(live, items)
+	}
+
+	size := 0
+	kept := make([]string, 0, len(live))
+	for i, key := range keys {
+		if _, ok := live[hashed[i]]; ok {
+			kept = append(kept, key)
+			size += len(key) + 1
+		}
+	}
+
+	// Keys are appended in order, so the oldest are evicted first
+	for size > memcachedTagIndexLimit {
+		c.client.Delete(c.hashKey(kept[0]))
+		size -= len(kept[0]) + 1
+		kept = kept[1:]
+	}
+
+	var b strings.Builder
+	for _, key := range kept {
+		b.WriteString(key)
+		b.WriteByte('\n')
+	}
+
+	item.Value = []byte(b.String())
+	item.Expiration = memcachedExpiration(memcachedMaxRelativeTTL)
+	c.client.CompareAndSwap(item)
+}
+
+func (c *memcachedCache) PurgeTag(tag string) int {
+	indexKey := c.hashKey("tags:" + tag)
+
+	item, err := c.client.Get(indexKey)
+	if err != nil {
+		return 0
+	}
+
+	purged := 0
+	for _, key := range uniqueLines(item.Value) {
+		if c.client.Delete(c.hashKey(key)) == nil {
+			purged++
+		}
+	}
+
+	c.client.Delete(indexKey)
+
+	c.mu.Lock()
+	delete(c.appended, tag)
+	c.mu.Unlock()
+
+	return purged
+}
+
+// PurgePrefix is not supported: Memcached cannot enumerate its keys, and the
+// keys are hashed before being stored. Use tags or exact URLs instead.
+func (c *memcachedCache) PurgePrefix(prefix string) (int, error) {
+	return 0, ErrPrefixPurgeUnsupported
+}
+
+func (c *memcachedCache) Stop() error {
+	return c.client.Close()
+}
+
+// uniqueLines returns the distinct non-empty lines of a tag index, in order
+func uniqueLines(value []byte) []string {
+	seen := make(map[string]bool)
+	var lines []string
+
+	for _, line := range strings.Split(string(value), "\n") {
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// memcachedExpiration converts a TTL into Memcached's expiration format
+func memcachedExpiration(ttl time.Duration) int32 {
+	if ttl > memcachedMaxRelativeTTL {
+		return int32(time.Now().Add(ttl).Unix())
+	}
+
+	// Round sub-second TTLs up, since 0 means "never expire"
+	seconds := int32(ttl / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
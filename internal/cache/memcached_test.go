@@ -0,0 +1,342 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+)
+
+// fakeMemcached speaks the part of the Memcached text protocol the client
+// uses. Expirations are tracked against a clock tests can move forward.
+type fakeMemcached struct {
+	listener net.Listener
+
+	mu     sync.Mutex
+	items  map[string]fakeItem
+	nextID uint64
+	now    time.Time
+}
+
+type fakeItem struct {
+	value     []byte
+	flags     uint32
+	casID     uint64
+	expiresAt time.Time
+}
+
+func newFakeMemcached(t *testing.T) *fakeMemcached {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeMemcached{
+		listener: listener,
+		items:    make(map[string]fakeItem),
+		now:      time.Now(),
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	return f
+}
+
+func (f *fakeMemcached) addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeMemcached) advance(d time.Duration) {
+	f.mu.Lock()
+	f.now = f.now.Add(d)
+	f.mu.Unlock()
+}
+
+func (f *fakeMemcached) len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, item := range f.items {
+		if item.expiresAt.IsZero() || f.now.Before(item.expiresAt) {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeMemcached) serve(conn net.Conn) {
+	defer conn.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch verb := fields[0]; verb {
+		case "get", "gets":
+			f.mu.Lock()
+			for _, key := range fields[1:] {
+				if item, ok := f.lookup(key); ok {
+					fmt.Fprintf(rw, "VALUE %s %d %d %d\r\n%s\r\n", key, item.flags, len(item.value), item.casID, item.value)
+				}
+			}
+			f.mu.Unlock()
+			rw.WriteString("END\r\n")
+
+		case "set", "add", "append", "cas":
+			flags, _ := strconv.ParseUint(fields[2], 10, 32)
+			exp, _ := strconv.ParseInt(fields[3], 10, 64)
+			size, _ := strconv.Atoi(fields[4])
+
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(rw, data); err != nil {
+				return
+			}
+
+			var casID uint64
+			if verb == "cas" {
+				casID, _ = strconv.ParseUint(fields[5], 10, 64)
+			}
+
+			f.mu.Lock()
+			rw.WriteString(f.store(verb, fields[1], uint32(flags), exp, data[:size], casID))
+			f.mu.Unlock()
+
+		case "delete":
+			f.mu.Lock()
+			if _, ok := f.lookup(fields[1]); ok {
+				delete(f.items, fields[1])
+				rw.WriteString("DELETED\r\n")
+			} else {
+				rw.WriteString("NOT_FOUND\r\n")
+			}
+			f.mu.Unlock()
+
+		default:
+			rw.WriteString("ERROR\r\n")
+		}
+
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// lookup returns the live item stored under key; f.mu must be held
+func (f *fakeMemcached) lookup(key string) (fakeItem, bool) {
+	item, ok := f.items[key]
+	if ok && !item.expiresAt.IsZero() && !f.now.Before(item.expiresAt) {
+		delete(f.items, key)
+		return fakeItem{}, false
+	}
+	return item, ok
+}
+
+// store applies a storage command and returns its reply; f.mu must be held
+func (f *fakeMemcached) store(verb, key string, flags uint32, exp int64, value []byte, casID uint64) string {
+	existing, found := f.lookup(key)
+
+	switch verb {
+	case "add":
+		if found {
+			return "NOT_STORED\r\n"
+		}
+	case "append":
+		if !found {
+			return "NOT_STORED\r\n"
+		}
+		value = append(append([]byte(nil), existing.value...), value...)
+		flags = existing.flags
+		exp = -1
+	case "cas":
+		if !found {
+			return "NOT_FOUND\r\n"
+		}
+		if existing.casID != casID {
+			return "EXISTS\r\n"
+		}
+	}
+
+	var expiresAt time.Time
+	switch {
+	case exp < 0:
+		expiresAt = existing.expiresAt
+	case exp > int64(memcachedMaxRelativeTTL/time.Second):
+		expiresAt = time.Unix(exp, 0)
+	case exp > 0:
+		expiresAt = f.now.Add(time.Duration(exp) * time.Second)
+	}
+
+	f.nextID++
+	f.items[key] = fakeItem{value: value, flags: flags, casID: f.nextID, expiresAt: expiresAt}
+	return "STORED\r\n"
+}
+
+func newTestMemcached(t *testing.T) (*memcachedCache, *fakeMemcached) {
+	t.Helper()
+
+	server := newFakeMemcached(t)
+	c := NewMemcachedCache(&config.CacheConfig{
+		Memcached: config.MemcachedConfig{
+			Servers:      []string{server.addr()},
+			KeyPrefix:    "rvx:",
+			MaxIdleConns: 2,
+			Timeout:      time.Second,
+		},
+	})
+	t.Cleanup(func() { c.Stop() })
+
+	return c, server
+}
+
+func TestMemcachedGetSetTTL(t *testing.T) {
+	c, server := newTestMemcached(t)
+
+	c.Set("expired", []byte("x"), 0)
+	if c.Exists("expired") {
+		t.Fatal("entry stored with a zero TTL")
+	}
+
+	// Keys that are not valid Memcached keys are hashed
+	key := "/search?q=two words"
+	c.Set(key, []byte("hello"), time.Minute)
+	if value, ok := c.Get(key); !ok || string(value) != "hello" {
+		t.Fatalf("Get = %q, %v; want hello, true", value, ok)
+	}
+
+	server.advance(time.Minute + time.Second)
+	if _, ok := c.Get(key); ok {
+		t.Fatal("entry still served after its TTL")
+	}
+
+	c.Set("/b", []byte("b"), time.Minute)
+	c.Delete("/b")
+	if c.Exists("/b") {
+		t.Fatal("entry still present after Delete")
+	}
+}
+
+func TestMemcachedTags(t *testing.T) {
+	c, server := newTestMemcached(t)
+
+	c.Set("/a", []byte("1"), time.Minute)
+	c.Set("/b", []byte("2"), time.Minute)
+	c.Set("/c", []byte("3"), time.Minute)
+
+	c.Tag("/a", "products")
+	c.Tag("/b", "products", "featured")
+	c.Tag("/a", "products")
+	c.Tag("/c", "featured")
+
+	if purged := c.PurgeTag("products"); purged != 2 {
+		t.Fatalf("PurgeTag = %d, want 2", purged)
+	}
+	if c.Exists("/a") || c.Exists("/b") || !c.Exists("/c") {
+		t.Fatal("PurgeTag removed the wrong entries")
+	}
+
+	// Only /c remains, plus the index of the other tag
+	if n := server.len(); n != 2 {
+		t.Fatalf("%d items left, want 2", n)
+	}
+
+	if purged := c.PurgeTag("featured"); purged != 1 {
+		t.Fatalf("PurgeTag = %d, want 1", purged)
+	}
+	if purged := c.PurgeTag("featured"); purged != 0 {
+		t.Fatalf("PurgeTag of a purged tag = %d, want 0", purged)
+	}
+
+	// Keys cannot be enumerated, so prefix purges must not report success
+	if _, err := c.PurgePrefix("/"); !errors.Is(err, ErrPrefixPurgeUnsupported) {
+		t.Fatalf("PurgePrefix error = %v, want ErrPrefixPurgeUnsupported", err)
+	}
+}
+
+func TestMemcachedTagIndexPruned(t *testing.T) {
+	c, server := newTestMemcached(t)
+	indexKey := c.hashKey("tags:pages")
+
+	index := func() []string {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		return uniqueLines(server.items[indexKey].value)
+	}
+
+	// Entries that expire leave their keys behind in the index
+	key := func(i int) string { return fmt.Sprintf("/pages/%04d/%s", i, strings.Repeat("x", 200)) }
+	i := 0
+	for ; (i+1)*(len(key(i))+1) < memcachedTagPruneBytes; i++ {
+		c.Set(key(i), []byte("v"), time.Second)
+		c.Tag(key(i), "pages")
+	}
+	server.advance(2 * time.Second)
+
+	c.Set(key(i), []byte("v"), time.Minute)
+	c.Tag(key(i), "pages")
+
+	if got := index(); len(got) != 1 || got[0] != key(i) {
+		t.Fatalf("index after prune holds %d keys, want only %s", len(got), key(i))
+	}
+
+	// The pruned index still drives purges
+	if purged := c.PurgeTag("pages"); purged != 1 {
+		t.Fatalf("PurgeTag = %d, want 1", purged)
+	}
+}
+
+func TestMemcachedSnapshot(t *testing.T) {
+	c, server := newTestMemcached(t)
+	c.Set("/a", []byte("hello"), time.Minute)
+
+	// Memcached outlives reverxy, so nothing is written to disk
+	path := filepath.Join(t.TempDir(), "cache.snap")
+	if n, err := SaveSnapshot(c, path); n != 0 || err != nil {
+		t.Fatalf("SaveSnapshot = %d, %v; want 0, nil", n, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("snapshot file written for a shared cache: %v", err)
+	}
+	c.Stop()
+
+	// A restarted replica finds the entries where it left them
+	restarted := NewMemcachedCache(&config.CacheConfig{
+		Memcached: config.MemcachedConfig{Servers: []string{server.addr()}, KeyPrefix: "rvx:", Timeout: time.Second},
+	})
+	defer restarted.Stop()
+
+	if n, err := LoadSnapshot(restarted, path); n != 0 || err != nil {
+		t.Fatalf("LoadSnapshot = %d, %v; want 0, nil", n, err)
+	}
+	if value, ok := restarted.Get("/a"); !ok || string(value) != "hello" {
+		t.Fatalf("Get after restart = %q, %v; want hello, true", value, ok)
+	}
+}
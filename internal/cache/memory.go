@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"strings"
 	"sync"
 	"time"
 
@@ -8,16 +9,19 @@ import (
 )
 
 type inMemoryCache struct {
-	mu     sync.RWMutex
-	store  map[string]*entry
-	
-	ticker *time.Ticker
-	stop   chan bool
+	mu    sync.RWMutex
+	store map[string]*entry
+	tags  map[string]map[string]struct{}
+
+	ticker   *time.Ticker
+	stop     chan bool
+	stopOnce sync.Once
 }
 
 func NewInMemoryCache(cfg *config.CacheConfig) *inMemoryCache {
 	cache := &inMemoryCache{
 		store:  make(map[string]*entry),
+		tags:   make(map[string]map[string]struct{}),
 		ticker: time.NewTicker(cfg.PurgeInterval),
 		stop:   make(chan bool),
 	}
@@ -31,12 +35,18 @@ type entry struct {
 	value     []byte
 	expiresAt time.Time
 	storedAt  time.Time
+	tags      []string
 }
 
 func (c *inMemoryCache) Set(key string, value []byte, ttl time.Duration) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	// A replaced entry must not stay reachable through its old tags
+	if old, exists := c.store[key]; exists {
+		c.untag(key, old.tags)
+	}
+
 	now := time.Now()
 	c.store[key] = &entry{
 		value:     value,
@@ -65,7 +75,7 @@ func (c *inMemoryCache) Get(key string) ([]byte, bool) {
 func (c *inMemoryCache) Delete(key string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	delete(c.store, key)
+	c.remove(key)
 }
 
 func (c *inMemoryCache) Exists(key string) bool {
@@ -76,11 +86,60 @@ func (c *inMemoryCache) Exists(key string) bool {
 	return exists
 }
 
-func (c *inMemoryCache) Stop() error {
+func (c *inMemoryCache) Tag(key string, tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, exists := c.store[key]
+	if !exists {
+		return
+	}
+
+	for _, tag := range tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+		if _, tagged := keys[key]; !tagged {
+			keys[key] = struct{}{}
+			e.tags = append(e.tags, tag)
+		}
+	}
+}
+
+func (c *inMemoryCache) PurgeTag(tag string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	purged := 0
+	for key := range c.tags[tag] {
+		if c.remove(key) {
+			purged++
+		}
+	}
+
+	delete(c.tags, tag)
+	return purged
+}
+
+func (c *inMemoryCache) PurgePrefix(prefix string) (int, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.stop <- true
+	purged := 0
+	for key := range c.store {
+		if strings.HasPrefix(key, prefix) && c.remove(key) {
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+func (c *inMemoryCache) Stop() error {
+	// Closing instead of sending never blocks on a cleanup holding the lock
+	c.stopOnce.Do(func() { close(c.stop) })
 	return nil
 }
 
@@ -103,7 +162,30 @@ func (c *inMemoryCache) cleanup() {
 	now := time.Now()
 	for key, e := range c.store {
 		if now.After(e.expiresAt) {
-			delete(c.store, key)
+			c.remove(key)
+		}
+	}
+}
+
+// remove deletes key and its tag index entries. Callers must hold the write lock.
+func (c *inMemoryCache) remove(key string) bool {
+	e, exists := c.store[key]
+	if !exists {
+		return false
+	}
+
+	c.untag(key, e.tags)
+	delete(c.store, key)
+	return true
+}
+
+// untag drops key from the given tag sets. Callers must hold the write lock.
+func (c *inMemoryCache) untag(key string, tags []string) {
+	for _, tag := range tags {
+		keys := c.tags[tag]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(c.tags, tag)
 		}
 	}
 }
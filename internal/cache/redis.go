@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisCache stores entries in a Redis server shared by every reverxy replica.
+// Any Redis error (including timeouts) is reported as a cache miss so a slow or
+// unavailable Redis never fails the request being proxied.
+type redisCache struct {
+	client  *redis.Client
+	prefix  string
+	timeout time.Duration
+}
+
+func NewRedisCache(cfg *config.CacheConfig) *redisCache {
+	client := redis.NewClient(&redis.Options{
+		Addr:         cfg.Redis.Addr,
+		Username:     cfg.Redis.Username,
+		Password:     cfg.Redis.Password,
+		DB:           cfg.Redis.DB,
+		PoolSize:     cfg.Redis.PoolSize,
+		MinIdleConns: cfg.Redis.MinIdleConns,
+		DialTimeout:  cfg.Redis.DialTimeout,
+		ReadTimeout:  cfg.Redis.Timeout,
+		WriteTimeout: cfg.Redis.Timeout,
+		PoolTimeout:  cfg.Redis.Timeout,
+		MaxRetries:   -1, // A retry would only add latency to a degraded cache
+	})
+
+	return &redisCache{
+		client:  client,
+		prefix:  cfg.Redis.KeyPrefix,
+		timeout: cfg.Redis.Timeout,
+	}
+}
+
+func (c *redisCache) Set(key string, value []byte, ttl time.Duration) {
+	// Redis treats a zero TTL as "never expire", so drop already-expired entries
+	if ttl <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	c.client.Set(ctx, c.prefix+key, value, ttl)
+}
+
+func (c *redisCache) Get(key string) ([]byte, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	value, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if err != nil {
+		return nil, false
+	}
+
+	return value, true
+}
+
+func (c *redisCache) Delete(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	c.client.Del(ctx, c.prefix+key)
+}
+
+func (c *redisCache) Exists(key string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	n, err := c.client.Exists(ctx, c.prefix+key).Result()
+	return err == nil && n > 0
+}
+
+// tagScript adds a key to each tag set in KEYS and extends the sets'
+// expiration to the key's TTL when that is longer. EXPIRE NX/GT would do the
+// same without a script but need Redis 7.
+var tagScript = redis.NewScript(`
+for _, tagKey in ipairs(KEYS) do
+	redis.call("SADD", tagKey, ARGV[1])
+	if redis.call("PTTL", tagKey) < tonumber(ARGV[2]) then
+		redis.call("PEXPIRE", tagKey, ARGV[2])
+	end
+end
+return #KEYS
+`)
+
+// Tag adds key to a Redis set per tag. Tag sets expire no earlier than the
+// longest-lived entry they reference.
+func (c *redisCache) Tag(key string, tags ...string) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	ttl, err := c.client.PTTL(ctx, c.prefix+key).Result()
+	if err != nil || ttl <= 0 {
+		return
+	}
+
+	tagKeys := make([]string, len(tags))
+	for i, tag := range tags {
+		tagKeys[i] = c.tagKey(tag)
+	}
+	tagScript.Run(ctx, c.client, tagKeys, key, ttl.Milliseconds())
+}
+
+func (c *redisCache) PurgeTag(tag string) int {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	tagKey := c.tagKey(tag)
+	keys, err := c.client.SMembers(ctx, tagKey).Result()
+	if err != nil {
+		return 0
+	}
+
+	purged := c.deleteKeys(ctx, keys)
+	c.client.Del(ctx, tagKey)
+
+	return purged
+}
+
+// PurgePrefix walks the keyspace with SCAN and deletes each batch of matches as
+// it goes. Every batch gets its own timeout, so a large keyspace is not cut
+// short by the per-command one; a failed batch stops the purge with an error.
+func (c *redisCache) PurgePrefix(prefix string) (int, error) {
+	pattern := c.prefix + escapeGlob(prefix) + "*"
+
+	purged := 0
+	var cursor uint64
+	for {
+		n, next, err := c.purgeBatch(cursor, pattern)
+		purged += n
+		if err != nil {
+			return purged, fmt.Errorf("purge prefix %q: %w", prefix, err)
+		}
+
+		if next == 0 {
+			return purged, nil
+		}
+		cursor = next
+	}
+}
+
+// purgeBatch runs one SCAN step from cursor and deletes the keys it returned
+func (c *redisCache) purgeBatch(cursor uint64, pattern string) (int, uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	keys, next, err := c.client.Scan(ctx, cursor, pattern, 500).Result()
+	if err != nil || len(keys) == 0 {
+		return 0, next, err
+	}
+
+	n, err := c.client.Del(ctx, keys...).Result()
+	return int(n), next, err
+}
+
+func (c *redisCache) Stop() error {
+	return c.client.Close()
+}
+
+func (c *redisCache) tagKey(tag string) string {
+	return c.prefix + "tags:" + tag
+}
+
+func (c *redisCache) deleteKeys(ctx context.Context, keys []string) int {
+	if len(keys) == 0 {
+		return 0
+	}
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.prefix + key
+	}
+
+	n, err := c.client.Del(ctx, prefixed...).Result()
+	if err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// escapeGlob escapes the characters SCAN MATCH treats as glob syntax
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
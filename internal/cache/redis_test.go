@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+)
+
+func newTestRedis(t *testing.T) (*redisCache, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	c := NewRedisCache(&config.CacheConfig{
+		Redis: config.RedisConfig{
+			Addr:        server.Addr(),
+			KeyPrefix:   "rvx:",
+			PoolSize:    2,
+			DialTimeout: time.Second,
+			Timeout:     time.Second,
+		},
+	})
+	t.Cleanup(func() { c.Stop() })
+
+	return c, server
+}
+
+func TestRedisGetSetTTL(t *testing.T) {
+	c, server := newTestRedis(t)
+
+	c.Set("expired", []byte("x"), 0)
+	if c.Exists("expired") {
+		t.Fatal("entry stored with a zero TTL")
+	}
+
+	c.Set("/a", []byte("hello"), time.Minute)
+	if value, ok := c.Get("/a"); !ok || string(value) != "hello" {
+		t.Fatalf("Get = %q, %v; want hello, true", value, ok)
+	}
+	if !server.Exists("rvx:/a") {
+		t.Fatal("key stored without the configured prefix")
+	}
+	if ttl := server.TTL("rvx:/a"); ttl != time.Minute {
+		t.Fatalf("TTL = %v, want 1m", ttl)
+	}
+
+	server.FastForward(time.Minute + time.Second)
+	if _, ok := c.Get("/a"); ok {
+		t.Fatal("entry still served after its TTL")
+	}
+
+	c.Set("/b", []byte("b"), time.Minute)
+	c.Delete("/b")
+	if c.Exists("/b") {
+		t.Fatal("entry still present after Delete")
+	}
+}
+
+func TestRedisTags(t *testing.T) {
+	c, server := newTestRedis(t)
+
+	c.Set("/short", []byte("1"), time.Minute)
+	c.Set("/long", []byte("2"), 5*time.Minute)
+	c.Set("/other", []byte("3"), time.Minute)
+
+	c.Tag("/long", "products", "featured")
+	c.Tag("/short", "products")
+	c.Tag("/other", "featured")
+
+	// The tag set outlives its longest-lived entry, whatever the tagging order
+	if ttl := server.TTL("rvx:tags:products"); ttl != 5*time.Minute {
+		t.Fatalf("tag TTL = %v, want 5m", ttl)
+	}
+
+	if purged := c.PurgeTag("products"); purged != 2 {
+		t.Fatalf("PurgeTag = %d, want 2", purged)
+	}
+	if c.Exists("/short") || c.Exists("/long") {
+		t.Fatal("tagged entries survived PurgeTag")
+	}
+	if server.Exists("rvx:tags:products") {
+		t.Fatal("tag set survived PurgeTag")
+	}
+
+	// Keys already gone are not counted
+	if purged := c.PurgeTag("featured"); purged != 1 {
+		t.Fatalf("PurgeTag = %d, want 1", purged)
+	}
+
+	c.Set("/img/a.png", []byte("a"), time.Minute)
+	c.Set("/img/b.png", []byte("b"), time.Minute)
+	c.Set("/imgx", []byte("x"), time.Minute)
+	if purged, err := c.PurgePrefix("/img/"); err != nil || purged != 2 {
+		t.Fatalf("PurgePrefix = %d, %v, want 2", purged, err)
+	}
+	if !c.Exists("/imgx") {
+		t.Fatal("PurgePrefix removed an entry outside the prefix")
+	}
+
+	// A scan that fails midway is reported, not passed off as complete
+	server.SetError("LOADING")
+	defer server.SetError("")
+	if _, err := c.PurgePrefix("/"); err == nil {
+		t.Fatal("PurgePrefix succeeded while Redis was failing")
+	}
+}
+
+func TestRedisSnapshot(t *testing.T) {
+	c, server := newTestRedis(t)
+	c.Set("/a", []byte("hello"), time.Minute)
+
+	// Redis outlives reverxy, so nothing is written to disk
+	path := filepath.Join(t.TempDir(), "cache.snap")
+	if n, err := SaveSnapshot(c, path); n != 0 || err != nil {
+		t.Fatalf("SaveSnapshot = %d, %v; want 0, nil", n, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("snapshot file written for a shared cache: %v", err)
+	}
+	c.Stop()
+
+	// A restarted replica finds the entries where it left them
+	restarted := NewRedisCache(&config.CacheConfig{
+		Redis: config.RedisConfig{Addr: server.Addr(), KeyPrefix: "rvx:", Timeout: time.Second},
+	})
+	defer restarted.Stop()
+
+	if n, err := LoadSnapshot(restarted, path); n != 0 || err != nil {
+		t.Fatalf("LoadSnapshot = %d, %v; want 0, nil", n, err)
+	}
+	if value, ok := restarted.Get("/a"); !ok || string(value) != "hello" {
+		t.Fatalf("Get after restart = %q, %v; want hello, true", value, ok)
+	}
+}
@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Snapshot files start with a magic header followed by one frame per entry:
+// a big-endian uint32 payload length, a CRC-32 of the payload and the gob
+// encoded snapshotEntry. Framing each entry lets a restore skip corrupt
+// entries instead of discarding the whole file.
+const (
+	snapshotMagic   = "RVXSNAP1"
+	maxSnapshotSize = 256 << 20 // Larger frames can only come from corruption
+)
+
+// Snapshotter is implemented by caches that can persist their contents
+// across restarts. Shared caches (redis, memcached) already outlive reverxy.
+type Snapshotter interface {
+	Snapshot(w io.Writer) (int, error)
+	Restore(r io.Reader) (int, error)
+}
+
+type snapshotEntry struct {
+	Key       string
+	Value     []byte
+	ExpiresAt time.Time
+	StoredAt  time.Time
+	Tags      []string
+}
+
+// SaveSnapshot writes the cache contents to path if the cache supports it.
+// The file is replaced atomically so a crash never leaves a half-written snapshot.
+func SaveSnapshot(c Cache, path string) (int, error) {
+	s, ok := c.(Snapshotter)
+	if !ok {
+		return 0, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return 0, fmt.Errorf("error creating snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	n, err := s.Snapshot(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error writing snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("error replacing snapshot: %w", err)
+	}
+
+	return n, nil
+}
+
+// LoadSnapshot restores the cache contents from path if the cache supports it.
+// A missing file is not an error: there is simply nothing to restore.
+func LoadSnapshot(c Cache, path string) (int, error) {
+	s, ok := c.(Snapshotter)
+	if !ok {
+		return 0, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error opening snapshot: %w", err)
+	}
+	defer file.Close()
+
+	return s.Restore(bufio.NewReader(file))
+}
+
+// Snapshot writes every non-expired entry and returns how many were written
+func (c *inMemoryCache) Snapshot(w io.Writer) (int, error) {
+	now := time.Now()
+
+	// Copy entries so encoding does not hold the lock
+	c.mu.RLock()
+	entries := make([]snapshotEntry, 0, len(c.store))
+	for key, e := range c.store {
+		if now.After(e.expiresAt) {
+			continue
+		}
+		entries = append(entries, snapshotEntry{
+			Key:       key,
+			Value:     e.value,
+			ExpiresAt: e.expiresAt,
+			StoredAt:  e.storedAt,
+			Tags:      append([]string(nil), e.tags...),
+		})
+	}
+	c.mu.RUnlock()
+
+	if _, err := io.WriteString(w, snapshotMagic); err != nil {
+		return 0, err
+	}
+
+	var buf bytes.Buffer
+	header := make([]byte, 8)
+	for i := range entries {
+		buf.Reset()
+		if err := gob.NewEncoder(&buf).Encode(&entries[i]); err != nil {
+			return i, err
+		}
+
+		binary.BigEndian.PutUint32(header[0:4], uint32(buf.Len()))
+		binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(buf.Bytes()))
+
+		if _, err := w.Write(header); err != nil {
+			return i, err
+		}
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return i, err
+		}
+	}
+
+	return len(entries), nil
+}
+
+// Restore loads entries written by Snapshot, keeping their original expiry so
+// only the remaining TTL is honored. Corrupt and expired entries are skipped;
+// a truncated file restores everything before the truncation.
+func (c *inMemoryCache) Restore(r io.Reader) (int, error) {
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != snapshotMagic {
+		return 0, fmt.Errorf("not a cache snapshot")
+	}
+
+	restored := 0
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			// io.EOF marks the clean end of the snapshot
+			return restored, nil
+		}
+
+		size := binary.BigEndian.Uint32(header[0:4])
+		if size > maxSnapshotSize {
+			// Without a trustworthy length the following frames cannot be located
+			return restored, fmt.Errorf("corrupt snapshot frame after %d entries", restored)
+		}
+
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return restored, nil
+		}
+
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+			continue
+		}
+
+		var e snapshotEntry
+		if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&e); err != nil {
+			continue
+		}
+
+		if c.restoreEntry(e) {
+			restored++
+		}
+	}
+}
+
+func (c *inMemoryCache) restoreEntry(e snapshotEntry) bool {
+	if !time.Now().Before(e.ExpiresAt) {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if old, exists := c.store[e.Key]; exists {
+		c.untag(e.Key, old.tags)
+	}
+
+	c.store[e.Key] = &entry{
+		value:     e.Value,
+		expiresAt: e.ExpiresAt,
+		storedAt:  e.StoredAt,
+	}
+
+	for _, tag := range e.Tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+		keys[e.Key] = struct{}{}
+	}
+	c.store[e.Key].tags = e.Tags
+
+	return true
+}
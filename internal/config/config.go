@@ -9,6 +9,8 @@ type Config struct {
 	Cache        CacheConfig        `yaml:"cache"`
 	LoadBalancer LoadBalancerConfig `yaml:"load_balancer"`
 	RateLimiter  RateLimiterConfig  `yaml:"rate_limiter"`
+	Admin        AdminConfig        `yaml:"admin"`
+	Compression  CompressionConfig  `yaml:"compression"`
 }
 
 type ProxyConfig struct {
@@ -17,21 +19,195 @@ type ProxyConfig struct {
 	ProbePort  string        `yaml:"probe_port"`
 	DefaultTTL time.Duration `yaml:"default_ttl"`
 	MaxAge     time.Duration `yaml:"max_age"`
+	Routes     []RouteConfig `yaml:"routes"`
+}
+
+// RouteConfig overrides cache freshness limits for requests under PathPrefix
+type RouteConfig struct {
+	PathPrefix string        `yaml:"path_prefix"`
+	DefaultTTL time.Duration `yaml:"default_ttl"`
+	MaxAge     time.Duration `yaml:"max_age"`
+}
+
+// CompressionConfig controls transparent response compression. Algorithms are
+// listed in order of preference; content types match on their media type.
+type CompressionConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	Algorithms   []string `yaml:"algorithms"`
+	ContentTypes []string `yaml:"content_types"`
+	MinSize      int      `yaml:"min_size"`
 }
 
 type CacheConfig struct {
-	Disabled      bool          `yaml:"disabled"`
-	PurgeInterval time.Duration `yaml:"purge_interval"`
+	Disabled      bool            `yaml:"disabled"`
+	Type          string          `yaml:"type"`
+	PurgeInterval time.Duration   `yaml:"purge_interval"`
+	SnapshotPath  string          `yaml:"snapshot_path"`
+	Key           CacheKeyConfig  `yaml:"cache_key"`
+	Warmup        WarmupConfig    `yaml:"warmup"`
+	Redis         RedisConfig     `yaml:"redis"`
+	Memcached     MemcachedConfig `yaml:"memcached"`
+}
+
+// CacheKeyConfig controls which parts of a request make up its cache key.
+// Query parameter names may end in "*" to match a prefix (e.g. "utm_*").
+type CacheKeyConfig struct {
+	IncludeQuery  []string `yaml:"include_query"`
+	ExcludeQuery  []string `yaml:"exclude_query"`
+	SortQuery     bool     `yaml:"sort_query"`
+	IgnoreCase    bool     `yaml:"ignore_case"`
+	IncludeHost   bool     `yaml:"include_host"`
+	IncludeScheme bool     `yaml:"include_scheme"`
+	Headers       []string `yaml:"headers"`
+	Cookies       []string `yaml:"cookies"`
+}
+
+// WarmupConfig lists the URLs fetched to populate the cache after a deploy.
+// Sitemap may be a local path or an http(s) URL.
+type WarmupConfig struct {
+	URLsFile    string        `yaml:"urls_file"`
+	Sitemap     string        `yaml:"sitemap"`
+	OnStartup   bool          `yaml:"on_startup"`
+	Concurrency int           `yaml:"concurrency"`
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+type RedisConfig struct {
+	Addr         string        `yaml:"addr"`
+	Username     string        `yaml:"username"`
+	Password     string        `yaml:"password"`
+	DB           int           `yaml:"db"`
+	KeyPrefix    string        `yaml:"key_prefix"`
+	PoolSize     int           `yaml:"pool_size"`
+	MinIdleConns int           `yaml:"min_idle_conns"`
+	DialTimeout  time.Duration `yaml:"dial_timeout"`
+	Timeout      time.Duration `yaml:"timeout"`
+}
+
+type MemcachedConfig struct {
+	Servers      []string      `yaml:"servers"`
+	KeyPrefix    string        `yaml:"key_prefix"`
+	MaxIdleConns int           `yaml:"max_idle_conns"`
+	Timeout      time.Duration `yaml:"timeout"`
 }
 
 type LoadBalancerConfig struct {
-	Type string     `yaml:"type"`
-	Pool PoolConfig `yaml:"pool"`
+	Type              string       `yaml:"type"`
+	FailoverThreshold float64      `yaml:"failover_threshold"`
+	Hash              HashConfig   `yaml:"hash"`
+	Sticky            StickyConfig `yaml:"sticky"`
+	Queue             QueueConfig  `yaml:"queue"`
+	Pool              PoolConfig   `yaml:"pool"`
+}
+
+// QueueConfig lets requests wait for a free connection slot when every backend
+// is at max_conns. Mode is "fifo" or "priority"; in priority mode requests with
+// a higher integer in PriorityHeader are served first.
+type QueueConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	MaxSize        int           `yaml:"max_size"`
+	Timeout        time.Duration `yaml:"timeout"`
+	Mode           string        `yaml:"mode"`
+	PriorityHeader string        `yaml:"priority_header"`
+}
+
+// StickyConfig configures session affinity. Mode selects how the affinity token
+// is handed to clients: "cookie" for browsers or "header" for other clients.
+// Tokens are accepted from either place.
+type StickyConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	Mode       string        `yaml:"mode"`
+	CookieName string        `yaml:"cookie_name"`
+	HeaderName string        `yaml:"header_name"`
+	TTL        time.Duration `yaml:"ttl"`
+	Secret     string        `yaml:"secret"`
+}
+
+// HashConfig configures the consistent-hash balancers (ring-hash and maglev).
+// Key is one of "ip", "header", "cookie" or "path"; Name is the header or
+// cookie name.
+type HashConfig struct {
+	Key          string `yaml:"key"`
+	Name         string `yaml:"name"`
+	VirtualNodes int    `yaml:"virtual_nodes"`
+	TableSize    int    `yaml:"table_size"`
 }
 
 type PoolConfig struct {
 	Backends      []BackendConfig     `yaml:"backends"`
 	HealthChecker HealthCheckerConfig `yaml:"health_checker"`
+	SlowStart     SlowStartConfig     `yaml:"slow_start"`
+	Discovery     DiscoveryConfig     `yaml:"discovery"`
+	DrainTimeout  time.Duration       `yaml:"drain_timeout"`
+}
+
+// DiscoveryConfig adds backends found at runtime to the static ones. Type
+// selects the source ("dns", "file", "kubernetes" or "consul"); an empty type disables discovery. Discovered
+// backends are built from Scheme, HealthPath, Weight, MaxConns and HealthCheck
+// unless the source provides its own values.
+type DiscoveryConfig struct {
+	Type            string                    `yaml:"type"`
+	RefreshInterval time.Duration             `yaml:"refresh_interval"`
+	Scheme          string                    `yaml:"scheme"`
+	HealthPath      string                    `yaml:"health_path"`
+	Weight          int                       `yaml:"weight"`
+	MaxConns        int                       `yaml:"max_conns"`
+	HealthCheck     HealthCheckConfig         `yaml:"health_check"`
+	DNS             DNSDiscoveryConfig        `yaml:"dns"`
+	File            FileDiscoveryConfig       `yaml:"file"`
+	Kubernetes      KubernetesDiscoveryConfig `yaml:"kubernetes"`
+	Consul          ConsulDiscoveryConfig     `yaml:"consul"`
+}
+
+// FileDiscoveryConfig reads the backends from a JSON or YAML file holding a
+// list of backend entries, re-read whenever it changes.
+type FileDiscoveryConfig struct {
+	Path         string        `yaml:"path"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+// KubernetesDiscoveryConfig watches the EndpointSlices of Service. Port is the
+// name or number of the service port to use, defaulting to its first port.
+// Kubeconfig is only needed when running outside the cluster.
+type KubernetesDiscoveryConfig struct {
+	Service    string `yaml:"service"`
+	Namespace  string `yaml:"namespace"`
+	Port       string `yaml:"port"`
+	Kubeconfig string `yaml:"kubeconfig"`
+}
+
+// ConsulDiscoveryConfig tracks the passing instances of Service in the Consul
+// catalog using blocking queries of up to Wait. Only instances carrying all
+// Tags are used. WeightMeta names the service metadata key holding the weight.
+type ConsulDiscoveryConfig struct {
+	Address    string        `yaml:"address"`
+	Service    string        `yaml:"service"`
+	Tags       []string      `yaml:"tags"`
+	Datacenter string        `yaml:"datacenter"`
+	Token      string        `yaml:"token"`
+	Wait       time.Duration `yaml:"wait"`
+	WeightMeta string        `yaml:"weight_meta"`
+}
+
+// DNSDiscoveryConfig resolves Name into one backend per address. RecordType is
+// "A", "AAAA", "A+AAAA" or "SRV"; SRV records supply port, weight and priority.
+// Resolver is the DNS server address, defaulting to /etc/resolv.conf.
+type DNSDiscoveryConfig struct {
+	Name       string        `yaml:"name"`
+	RecordType string        `yaml:"record_type"`
+	Port       int           `yaml:"port"`
+	Resolver   string        `yaml:"resolver"`
+	Timeout    time.Duration `yaml:"timeout"`
+	MinTTL     time.Duration `yaml:"min_ttl"`
+}
+
+// SlowStartConfig ramps the effective weight of a backend that just became
+// healthy from MinWeight to full over Window. Aggression shapes the curve:
+// 1 is linear, higher values ramp faster early on.
+type SlowStartConfig struct {
+	Window     time.Duration `yaml:"window"`
+	MinWeight  float64       `yaml:"min_weight"`
+	Aggression float64       `yaml:"aggression"`
 }
 
 type BackendConfig struct {
@@ -40,12 +216,41 @@ type BackendConfig struct {
 	HealthUrl string `yaml:"health_url"`
 	Weight    int    `yaml:"weight"`
 	MaxConns  int    `yaml:"max_conns"`
+	Priority  int    `yaml:"priority"`
+	Backup    bool   `yaml:"backup"`
+	Drain     bool   `yaml:"drain"`
+
+	Labels      map[string]string `yaml:"labels"`
+	HealthCheck HealthCheckConfig `yaml:"health_check"`
 }
 
+// HealthCheckConfig customizes how a backend's health URL is probed. Expected
+// status entries are codes ("200"), ranges ("200-399") or classes ("2xx"),
+// defaulting to 2xx. The body assertions all have to hold: BodyContains is a
+// substring, BodyRegex a regular expression, and JSONPath a dotted path into a
+// JSON body ("checks.0.status") whose value must equal JSONValue, or merely
+// exist when JSONValue is empty. Port sends the check to another port.
+type HealthCheckConfig struct {
+	Method         string            `yaml:"method"`
+	Host           string            `yaml:"host"`
+	Headers        map[string]string `yaml:"headers"`
+	ExpectedStatus []string          `yaml:"expected_status"`
+	BodyContains   string            `yaml:"body_contains"`
+	BodyRegex      string            `yaml:"body_regex"`
+	JSONPath       string            `yaml:"json_path"`
+	JSONValue      string            `yaml:"json_value"`
+	Port           int               `yaml:"port"`
+}
+
+// HealthCheckerConfig configures the health checks. A healthy backend is only
+// marked unhealthy after UnhealthyThreshold consecutive failed checks, and an
+// unhealthy one needs HealthyThreshold consecutive successes to recover.
 type HealthCheckerConfig struct {
 	MaxConcurrentChecks int           `yaml:"max_concurrent_checks"`
 	Interval            time.Duration `yaml:"interval"`
 	Timeout             time.Duration `yaml:"timeout"`
+	HealthyThreshold    int           `yaml:"healthy_threshold"`
+	UnhealthyThreshold  int           `yaml:"unhealthy_threshold"`
 }
 
 type RateLimiterConfig struct {
@@ -55,3 +260,7 @@ type RateLimiterConfig struct {
 	Capacity       int      `yaml:"capacity"`
 	RefillRate     int      `yaml:"refill_rate"`
 }
+
+type AdminConfig struct {
+	Token string `yaml:"token"`
+}
@@ -2,6 +2,10 @@ package config
 
 import (
 	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -16,20 +20,70 @@ const (
 	DefaultMaxAge    = 24 * time.Hour  // Reasonable upper bound
 
 	// Cache defaults
-	DefaultPurgeInterval = 10 * time.Minute // Cleanup frequency
+	DefaultCacheType         = "memory"
+	DefaultPurgeInterval     = 10 * time.Minute // Cleanup frequency
+	DefaultCacheTimeout      = 100 * time.Millisecond
+	DefaultCacheDialTimeout  = 1 * time.Second
+	DefaultCacheKeyPrefix    = "reverxy:"
+	DefaultRedisAddr         = "localhost:6379"
+	DefaultRedisPoolSize     = 20
+	DefaultMemcachedServer   = "localhost:11211"
+	DefaultMemcachedMaxIdle  = 20
+	DefaultWarmupConcurrency = 4
+	DefaultWarmupTimeout     = 30 * time.Second
+
+	// Compression defaults
+	DefaultCompressionMinSize = 1024 // Smaller bodies rarely benefit
 
 	// Backend defaults
 	DefaultName     = "backend"
 	DefaultWeight   = 1
 	DefaultMaxConns = 100
 
+	// Health check request defaults
+	DefaultHealthCheckMethod = "GET"
+	DefaultHealthCheckStatus = "2xx"
+
+	// Drain defaults
+	DefaultDrainTimeout = 5 * time.Minute // Then a drain completes regardless of open requests
+
+	// Discovery defaults
+	DefaultDiscoveryRefresh    = 30 * time.Second
+	DefaultDiscoveryScheme     = "http"
+	DefaultDiscoveryHealthPath = "/health"
+	DefaultDNSRecordType       = "A+AAAA"
+	DefaultDNSTimeout          = 2 * time.Second
+	DefaultDNSMinTTL           = 5 * time.Second // Floor for very short TTLs
+	DefaultFilePollInterval    = 5 * time.Second
+	DefaultConsulAddress       = "http://127.0.0.1:8500"
+	DefaultConsulWait          = 5 * time.Minute // Consul's own maximum
+	DefaultConsulWeightMeta    = "weight"
+
+	// Slow start defaults (disabled unless a window is set)
+	DefaultSlowStartMinWeight  = 0.1
+	DefaultSlowStartAggression = 1.0 // Linear ramp
+
 	// Health check defaults
 	DefaultTimeout             = 5 * time.Second
 	DefaultInterval            = 10 * time.Second
 	DefaultMaxConcurrentChecks = 10
+	DefaultHealthyThreshold    = 2 // Consecutive successes to recover
+	DefaultUnhealthyThreshold  = 3 // Consecutive failures to go down
 
 	// Load balancer defaults
-	DefaultLoadBalancerType = "round-robin"
+	DefaultLoadBalancerType  = "round-robin"
+	DefaultFailoverThreshold = 0.5 // Healthy fraction below which a tier fails over
+	DefaultHashKey           = "ip"
+	DefaultVirtualNodes      = 100   // Ring points per unit of weight
+	DefaultMaglevTableSize   = 65537 // Prime, well above 100x the backend count
+	DefaultStickyMode        = "cookie"
+	DefaultStickyCookieName  = "reverxy_affinity"
+	DefaultStickyHeaderName  = "X-Reverxy-Affinity"
+	DefaultStickyTTL         = 1 * time.Hour
+	DefaultQueueMaxSize      = 100
+	DefaultQueueTimeout      = 5 * time.Second
+	DefaultQueueMode         = "fifo"
+	DefaultQueueHeader       = "X-Priority"
 
 	// Rate limiter defaults
 	DefaultRateLimiterType = "fixed-window"
@@ -40,6 +94,20 @@ const (
 
 var DefaultTrustedProxies = []string{"", ""}
 
+var DefaultCompressionAlgorithms = []string{"br", "zstd", "gzip"}
+
+var DefaultCompressionContentTypes = []string{
+	"text/html",
+	"text/css",
+	"text/plain",
+	"text/xml",
+	"text/javascript",
+	"application/javascript",
+	"application/json",
+	"application/xml",
+	"image/svg+xml",
+}
+
 func (c *Config) applyDefaults() error {
 
 	// Apply defaults for proxy config
@@ -63,52 +131,135 @@ func (c *Config) applyDefaults() error {
 		c.Proxy.MaxAge = DefaultMaxAge
 	}
 
+	// Routes inherit any limit they do not override
+	for i := range c.Proxy.Routes {
+		route := &c.Proxy.Routes[i]
+
+		if route.PathPrefix == "" {
+			return fmt.Errorf("route %d missing path_prefix", i)
+		}
+
+		if route.DefaultTTL == 0 {
+			route.DefaultTTL = c.Proxy.DefaultTTL
+		}
+
+		if route.MaxAge == 0 {
+			route.MaxAge = c.Proxy.MaxAge
+		}
+	}
+
 	// Apply defaults for cache config
 
 	// Note: cache.Disabled defaults to false (cache enabled by default)
 
+	if c.Cache.Type == "" {
+		c.Cache.Type = DefaultCacheType
+	}
+
 	if c.Cache.PurgeInterval == 0 {
 		c.Cache.PurgeInterval = DefaultPurgeInterval
 	}
 
-	// Apply defaults for backend pool config
-	if c.LoadBalancer.Pool.Backends == nil {
-		return fmt.Errorf("backend pool config is missing")
+	if c.Cache.Warmup.Concurrency == 0 {
+		c.Cache.Warmup.Concurrency = DefaultWarmupConcurrency
+	}
+
+	if c.Cache.Warmup.Timeout == 0 {
+		c.Cache.Warmup.Timeout = DefaultWarmupTimeout
 	}
 
-	if len(c.LoadBalancer.Pool.Backends) == 0 {
+	switch c.Cache.Type {
+	case "memory":
+	case "redis":
+		if c.Cache.Redis.Addr == "" {
+			c.Cache.Redis.Addr = DefaultRedisAddr
+		}
+		if c.Cache.Redis.KeyPrefix == "" {
+			c.Cache.Redis.KeyPrefix = DefaultCacheKeyPrefix
+		}
+		if c.Cache.Redis.PoolSize == 0 {
+			c.Cache.Redis.PoolSize = DefaultRedisPoolSize
+		}
+		if c.Cache.Redis.DialTimeout == 0 {
+			c.Cache.Redis.DialTimeout = DefaultCacheDialTimeout
+		}
+		if c.Cache.Redis.Timeout == 0 {
+			c.Cache.Redis.Timeout = DefaultCacheTimeout
+		}
+	case "memcached":
+		if len(c.Cache.Memcached.Servers) == 0 {
+			c.Cache.Memcached.Servers = []string{DefaultMemcachedServer}
+		}
+		if c.Cache.Memcached.KeyPrefix == "" {
+			c.Cache.Memcached.KeyPrefix = DefaultCacheKeyPrefix
+		}
+		if c.Cache.Memcached.MaxIdleConns == 0 {
+			c.Cache.Memcached.MaxIdleConns = DefaultMemcachedMaxIdle
+		}
+		if c.Cache.Memcached.Timeout == 0 {
+			c.Cache.Memcached.Timeout = DefaultCacheTimeout
+		}
+	default:
+		return fmt.Errorf("unknown cache type %q", c.Cache.Type)
+	}
+
+	// Apply defaults for compression config
+	if c.Compression.Algorithms == nil {
+		c.Compression.Algorithms = DefaultCompressionAlgorithms
+	}
+
+	for _, algorithm := range c.Compression.Algorithms {
+		switch algorithm {
+		case "br", "zstd", "gzip":
+		default:
+			return fmt.Errorf("unknown compression algorithm %q", algorithm)
+		}
+	}
+
+	if c.Compression.ContentTypes == nil {
+		c.Compression.ContentTypes = DefaultCompressionContentTypes
+	}
+
+	if c.Compression.MinSize == 0 {
+		c.Compression.MinSize = DefaultCompressionMinSize
+	}
+
+	// Apply defaults for backend pool config; with discovery the static list may be empty
+	if err := c.LoadBalancer.Pool.Discovery.applyDefaults(); err != nil {
+		return err
+	}
+
+	if len(c.LoadBalancer.Pool.Backends) == 0 && c.LoadBalancer.Pool.Discovery.Type == "" {
 		return fmt.Errorf("no backends configured")
 	}
 
 	// Apply defaults for backend config
 	for i := range c.LoadBalancer.Pool.Backends {
 		// take pointer to element so we mutate the slice element directly
-		b := &c.LoadBalancer.Pool.Backends[i]
-
-		if b.Name == "" {
-			b.Name = DefaultName + strconv.Itoa(i)
+		if err := c.LoadBalancer.Pool.Backends[i].ApplyDefaults(i); err != nil {
+			return err
 		}
+	}
 
-		if b.Url == "" {
-			return fmt.Errorf("%s missing URL", b.Name)
-		}
+	if c.LoadBalancer.Pool.DrainTimeout == 0 {
+		c.LoadBalancer.Pool.DrainTimeout = DefaultDrainTimeout
+	}
 
-		// If health_url is empty, build it from Url; if it's a relative path like "/health",
-		// prepend the backend URL.
-		if b.HealthUrl == "" {
-			b.HealthUrl = strings.TrimRight(b.Url, "/") + "/health"
-		} else if strings.HasPrefix(b.HealthUrl, "/") {
-			// relative path -> join with base URL
-			b.HealthUrl = strings.TrimRight(b.Url, "/") + b.HealthUrl
-		}
+	// Apply defaults for slow start config
+	if c.LoadBalancer.Pool.SlowStart.MinWeight == 0 {
+		c.LoadBalancer.Pool.SlowStart.MinWeight = DefaultSlowStartMinWeight
+	}
 
-		if b.Weight == 0 {
-			b.Weight = DefaultWeight
-		}
+	if c.LoadBalancer.Pool.SlowStart.Aggression == 0 {
+		c.LoadBalancer.Pool.SlowStart.Aggression = DefaultSlowStartAggression
+	}
 
-		if b.MaxConns == 0 {
-			b.MaxConns = DefaultMaxConns
-		}
+	if c.LoadBalancer.Pool.SlowStart.MinWeight < 0 || c.LoadBalancer.Pool.SlowStart.MinWeight > 1 {
+		return fmt.Errorf("slow_start min_weight must be between 0 and 1")
+	}
+
+	if c.LoadBalancer.Pool.SlowStart.Aggression < 0 {
+		return fmt.Errorf("slow_start aggression must be positive")
 	}
 
 	// Apply defaults for health checker config
@@ -124,11 +275,87 @@ func (c *Config) applyDefaults() error {
 		c.LoadBalancer.Pool.HealthChecker.MaxConcurrentChecks = DefaultMaxConcurrentChecks
 	}
 
+	if c.LoadBalancer.Pool.HealthChecker.HealthyThreshold == 0 {
+		c.LoadBalancer.Pool.HealthChecker.HealthyThreshold = DefaultHealthyThreshold
+	}
+
+	if c.LoadBalancer.Pool.HealthChecker.UnhealthyThreshold == 0 {
+		c.LoadBalancer.Pool.HealthChecker.UnhealthyThreshold = DefaultUnhealthyThreshold
+	}
+
+	if c.LoadBalancer.Pool.HealthChecker.HealthyThreshold < 0 || c.LoadBalancer.Pool.HealthChecker.UnhealthyThreshold < 0 {
+		return fmt.Errorf("health checker thresholds must be positive")
+	}
+
 	// Apply defaults for load balancer config
 	if c.LoadBalancer.Type == "" {
 		c.LoadBalancer.Type = DefaultLoadBalancerType
 	}
 
+	switch c.LoadBalancer.Type {
+	case "round-robin", "weighted-round-robin", "least-connections", "random-weight", "p2c", "least-latency":
+	case "ring-hash", "maglev":
+		if err := c.LoadBalancer.Hash.applyDefaults(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown load balancer type %q", c.LoadBalancer.Type)
+	}
+
+	if c.LoadBalancer.FailoverThreshold == 0 {
+		c.LoadBalancer.FailoverThreshold = DefaultFailoverThreshold
+	}
+
+	if c.LoadBalancer.FailoverThreshold < 0 || c.LoadBalancer.FailoverThreshold > 1 {
+		return fmt.Errorf("failover_threshold must be between 0 and 1")
+	}
+
+	// Apply defaults for the wait queue config
+	if c.LoadBalancer.Queue.MaxSize == 0 {
+		c.LoadBalancer.Queue.MaxSize = DefaultQueueMaxSize
+	}
+
+	if c.LoadBalancer.Queue.Timeout == 0 {
+		c.LoadBalancer.Queue.Timeout = DefaultQueueTimeout
+	}
+
+	if c.LoadBalancer.Queue.Mode == "" {
+		c.LoadBalancer.Queue.Mode = DefaultQueueMode
+	}
+
+	if c.LoadBalancer.Queue.Mode != "fifo" && c.LoadBalancer.Queue.Mode != "priority" {
+		return fmt.Errorf("unknown queue mode %q", c.LoadBalancer.Queue.Mode)
+	}
+
+	if c.LoadBalancer.Queue.PriorityHeader == "" {
+		c.LoadBalancer.Queue.PriorityHeader = DefaultQueueHeader
+	}
+
+	// Apply defaults for sticky sessions config
+	if c.LoadBalancer.Sticky.Enabled {
+		sticky := &c.LoadBalancer.Sticky
+
+		if sticky.Mode == "" {
+			sticky.Mode = DefaultStickyMode
+		}
+
+		if sticky.Mode != "cookie" && sticky.Mode != "header" {
+			return fmt.Errorf("unknown sticky mode %q", sticky.Mode)
+		}
+
+		if sticky.CookieName == "" {
+			sticky.CookieName = DefaultStickyCookieName
+		}
+
+		if sticky.HeaderName == "" {
+			sticky.HeaderName = DefaultStickyHeaderName
+		}
+
+		if sticky.TTL == 0 {
+			sticky.TTL = DefaultStickyTTL
+		}
+	}
+
 	// Apply defaults for rate limiter config
 	if c.RateLimiter.Type == "" {
 		c.RateLimiter.Type = DefaultRateLimiterType
@@ -152,3 +379,241 @@ func (c *Config) applyDefaults() error {
 
 	return nil
 }
+
+func (h *HashConfig) applyDefaults() error {
+	if h.Key == "" {
+		h.Key = DefaultHashKey
+	}
+
+	switch h.Key {
+	case "ip", "path":
+	case "header", "cookie":
+		if h.Name == "" {
+			return fmt.Errorf("hash key %q requires a name", h.Key)
+		}
+	default:
+		return fmt.Errorf("unknown hash key %q", h.Key)
+	}
+
+	if h.VirtualNodes == 0 {
+		h.VirtualNodes = DefaultVirtualNodes
+	}
+
+	if h.TableSize == 0 {
+		h.TableSize = DefaultMaglevTableSize
+	}
+
+	if !big.NewInt(int64(h.TableSize)).ProbablyPrime(0) {
+		return fmt.Errorf("maglev table_size %d is not prime", h.TableSize)
+	}
+
+	return nil
+}
+
+// ApplyDefaults fills in the defaults of a backend and validates it. index is
+// its position in the backend list, used to name unnamed backends.
+func (b *BackendConfig) ApplyDefaults(index int) error {
+	if b.Name == "" {
+		b.Name = DefaultName + strconv.Itoa(index)
+	}
+
+	if b.Url == "" {
+		return fmt.Errorf("%s missing URL", b.Name)
+	}
+
+	// If health_url is empty, build it from Url; if it's a relative path like "/health",
+	// prepend the backend URL.
+	if b.HealthUrl == "" {
+		b.HealthUrl = strings.TrimRight(b.Url, "/") + "/health"
+	} else if strings.HasPrefix(b.HealthUrl, "/") {
+		// relative path -> join with base URL
+		b.HealthUrl = strings.TrimRight(b.Url, "/") + b.HealthUrl
+	}
+
+	if err := b.HealthCheck.applyDefaults(); err != nil {
+		return fmt.Errorf("%s health check: %w", b.Name, err)
+	}
+
+	// A separate health port replaces the port of the health URL
+	if b.HealthCheck.Port != 0 {
+		healthUrl, err := url.Parse(b.HealthUrl)
+		if err != nil {
+			return fmt.Errorf("%s has invalid health_url: %w", b.Name, err)
+		}
+		healthUrl.Host = net.JoinHostPort(healthUrl.Hostname(), strconv.Itoa(b.HealthCheck.Port))
+		b.HealthUrl = healthUrl.String()
+	}
+
+	if b.Weight == 0 {
+		b.Weight = DefaultWeight
+	}
+
+	if b.Weight < 0 {
+		return fmt.Errorf("%s has negative weight", b.Name)
+	}
+
+	if b.Priority < 0 {
+		return fmt.Errorf("%s has negative priority", b.Name)
+	}
+
+	if b.MaxConns == 0 {
+		b.MaxConns = DefaultMaxConns
+	}
+
+	return nil
+}
+
+func (d *DiscoveryConfig) applyDefaults() error {
+	switch d.Type {
+	case "":
+		return nil
+	case "dns":
+		if err := d.DNS.applyDefaults(); err != nil {
+			return err
+		}
+	case "file":
+		if d.File.Path == "" {
+			return fmt.Errorf("file discovery requires a path")
+		}
+		if d.File.PollInterval == 0 {
+			d.File.PollInterval = DefaultFilePollInterval
+		}
+	case "kubernetes":
+		if d.Kubernetes.Service == "" {
+			return fmt.Errorf("kubernetes discovery requires a service")
+		}
+	case "consul":
+		if d.Consul.Service == "" {
+			return fmt.Errorf("consul discovery requires a service")
+		}
+		if d.Consul.Address == "" {
+			d.Consul.Address = DefaultConsulAddress
+		}
+		if d.Consul.Wait == 0 {
+			d.Consul.Wait = DefaultConsulWait
+		}
+		if d.Consul.WeightMeta == "" {
+			d.Consul.WeightMeta = DefaultConsulWeightMeta
+		}
+	default:
+		return fmt.Errorf("unknown discovery type %q", d.Type)
+	}
+
+	if d.RefreshInterval == 0 {
+		d.RefreshInterval = DefaultDiscoveryRefresh
+	}
+
+	if d.Scheme == "" {
+		d.Scheme = DefaultDiscoveryScheme
+	}
+
+	if d.HealthPath == "" {
+		d.HealthPath = DefaultDiscoveryHealthPath
+	}
+
+	if d.Weight == 0 {
+		d.Weight = DefaultWeight
+	}
+
+	if d.MaxConns == 0 {
+		d.MaxConns = DefaultMaxConns
+	}
+
+	// Validated on a copy: the block is merged into discovered backends, whose
+	// own settings take precedence, before their defaults are applied
+	check := d.HealthCheck
+	if err := check.applyDefaults(); err != nil {
+		return fmt.Errorf("discovery health check: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DNSDiscoveryConfig) applyDefaults() error {
+	if d.Name == "" {
+		return fmt.Errorf("dns discovery requires a name")
+	}
+
+	if d.RecordType == "" {
+		d.RecordType = DefaultDNSRecordType
+	}
+
+	switch d.RecordType {
+	case "A", "AAAA", "A+AAAA":
+		if d.Port == 0 {
+			return fmt.Errorf("dns discovery of %s records requires a port", d.RecordType)
+		}
+	case "SRV":
+	default:
+		return fmt.Errorf("unknown dns record type %q", d.RecordType)
+	}
+
+	if d.Timeout == 0 {
+		d.Timeout = DefaultDNSTimeout
+	}
+
+	if d.MinTTL == 0 {
+		d.MinTTL = DefaultDNSMinTTL
+	}
+
+	return nil
+}
+
+func (h *HealthCheckConfig) applyDefaults() error {
+	if h.Method == "" {
+		h.Method = DefaultHealthCheckMethod
+	}
+	h.Method = strings.ToUpper(h.Method)
+
+	if len(h.ExpectedStatus) == 0 {
+		h.ExpectedStatus = []string{DefaultHealthCheckStatus}
+	}
+
+	for _, status := range h.ExpectedStatus {
+		if _, _, err := ParseStatusRange(status); err != nil {
+			return err
+		}
+	}
+
+	if h.BodyRegex != "" {
+		if _, err := regexp.Compile(h.BodyRegex); err != nil {
+			return fmt.Errorf("invalid body_regex: %w", err)
+		}
+	}
+
+	if h.JSONValue != "" && h.JSONPath == "" {
+		return fmt.Errorf("json_value requires a json_path")
+	}
+
+	if h.Port < 0 || h.Port > 65535 {
+		return fmt.Errorf("invalid port %d", h.Port)
+	}
+
+	return nil
+}
+
+// ParseStatusRange parses an expected status: a code ("200"), an inclusive
+// range ("200-399") or a class ("2xx")
+func ParseStatusRange(status string) (int, int, error) {
+	status = strings.TrimSpace(status)
+
+	if len(status) == 3 && strings.HasSuffix(strings.ToLower(status), "xx") {
+		class, err := strconv.Atoi(status[:1])
+		if err == nil && class >= 1 && class <= 5 {
+			return class * 100, class*100 + 99, nil
+		}
+	}
+
+	low, high, isRange := strings.Cut(status, "-")
+	lo, err := strconv.Atoi(strings.TrimSpace(low))
+	hi := lo
+	if err == nil && isRange {
+		hi, err = strconv.Atoi(strings.TrimSpace(high))
+	}
+
+	if err != nil || lo < 100 || hi > 599 || lo > hi {
+		return 0, 0, fmt.Errorf("invalid expected status %q", status)
+	}
+
+	return lo, hi, nil
+}
@@ -0,0 +1,124 @@
+package loadbalancer
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+	"github.com/Lucascluz/reverxy/internal/loadbalancer/pool"
+)
+
+// affinity pins clients to a backend with a signed token naming it. The token
+// is "<base64 backend name>.<unix expiry>.<base64 HMAC-SHA256>", so clients can
+// neither forge a binding nor extend it past its TTL.
+type affinity struct {
+	cfg    config.StickyConfig
+	secret []byte
+}
+
+func newAffinity(cfg config.StickyConfig) *affinity {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	secret := []byte(cfg.Secret)
+	if len(secret) == 0 {
+		// Tokens then only survive as long as this process, and only on this replica
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+
+	return &affinity{cfg: cfg, secret: secret}
+}
+
+// lookup returns the backend name bound to r and when the binding expires
+func (a *affinity) lookup(r *http.Request) (string, time.Time, bool) {
+	token := r.Header.Get(a.cfg.HeaderName)
+	if token == "" {
+		if cookie, err := r.Cookie(a.cfg.CookieName); err == nil {
+			token = cookie.Value
+		}
+	}
+
+	return a.verify(token)
+}
+
+// bind hands the client a token for backend unless its current one already
+// names that backend and is less than halfway through its TTL.
+func (a *affinity) bind(w http.ResponseWriter, r *http.Request, backend *pool.Backend) {
+	name, expires, ok := a.lookup(r)
+	if ok && name == backend.Name() && time.Until(expires) > a.cfg.TTL/2 {
+		return
+	}
+
+	token := a.sign(backend.Name(), time.Now().Add(a.cfg.TTL))
+
+	if a.cfg.Mode == "header" {
+		w.Header().Set(a.cfg.HeaderName, token)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.cfg.CookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(a.cfg.TTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (a *affinity) sign(name string, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(name)) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(a.mac(payload))
+}
+
+func (a *affinity) verify(token string) (string, time.Time, bool) {
+	payload, signature, ok := cutLast(token, ".")
+	if !ok {
+		return "", time.Time{}, false
+	}
+
+	provided, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(provided, a.mac(payload)) {
+		return "", time.Time{}, false
+	}
+
+	encodedName, expiry, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", time.Time{}, false
+	}
+
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || time.Now().Unix() >= unix {
+		return "", time.Time{}, false
+	}
+
+	name, err := base64.RawURLEncoding.DecodeString(encodedName)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	return string(name), time.Unix(unix, 0), true
+}
+
+func (a *affinity) mac(payload string) []byte {
+	h := hmac.New(sha256.New, a.secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return "", "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
@@ -0,0 +1,49 @@
+package balancer
+
+import (
+	"net/http"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+	"github.com/Lucascluz/reverxy/internal/loadbalancer/pool"
+	"github.com/cespare/xxhash/v2"
+)
+
+// KeyFunc extracts the request attribute consistent-hash balancers route on
+type KeyFunc func(r *http.Request) string
+
+// NewKeyFunc builds the KeyFunc selected by cfg. Requests missing the
+// configured header or cookie fall back to clientIP, which should resolve the
+// client behind trusted proxies so traffic relayed by them is still spread.
+func NewKeyFunc(cfg config.HashConfig, clientIP KeyFunc) KeyFunc {
+	switch cfg.Key {
+	case "header":
+		return func(r *http.Request) string {
+			if value := r.Header.Get(cfg.Name); value != "" {
+				return value
+			}
+			return clientIP(r)
+		}
+	case "cookie":
+		return func(r *http.Request) string {
+			if cookie, err := r.Cookie(cfg.Name); err == nil && cookie.Value != "" {
+				return cookie.Value
+			}
+			return clientIP(r)
+		}
+	case "path":
+		return func(r *http.Request) string {
+			return r.URL.Path
+		}
+	default:
+		return clientIP
+	}
+}
+
+func hashString(s string) uint64 {
+	return xxhash.Sum64String(s)
+}
+
+// isAvailable reports whether a backend can take a new request
+func isAvailable(backend *pool.Backend) bool {
+	return backend.IsAvailable() && !backend.IsAtCapacity()
+}
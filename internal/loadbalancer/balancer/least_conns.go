@@ -20,10 +20,13 @@ func (lc *leastConns) Next() *pool.Backend {
 		return nil
 	}
 
+	// Backends in slow start look proportionally busier than they are
 	var least *pool.Backend
+	var leastLoad float64
 	for _, backend := range lc.backends {
-		if least == nil || backend.ActiveConns() < least.ActiveConns() {
-			least = backend
+		load := float64(backend.ActiveConns()+1) / backend.SlowStartFactor()
+		if least == nil || load < leastLoad {
+			least, leastLoad = backend, load
 		}
 	}
 
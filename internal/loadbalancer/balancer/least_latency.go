@@ -0,0 +1,34 @@
+package balancer
+
+import (
+	"github.com/Lucascluz/reverxy/internal/loadbalancer/pool"
+)
+
+// leastLatency scans every backend for the lowest latency score
+type leastLatency struct {
+	backends []*pool.Backend
+}
+
+func NewLeastLatency(backends []*pool.Backend) *leastLatency {
+	return &leastLatency{
+		backends: backends,
+	}
+}
+
+func (ll *leastLatency) Next() *pool.Backend {
+	var best *pool.Backend
+	var bestScore float64
+
+	for _, backend := range ll.backends {
+		if !isAvailable(backend) {
+			continue
+		}
+
+		score := latencyScore(backend)
+		if best == nil || score < bestScore {
+			best, bestScore = backend, score
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,109 @@
+package balancer
+
+import (
+	"net/http"
+
+	"github.com/Lucascluz/reverxy/internal/loadbalancer/pool"
+)
+
+// maglev implements Google's Maglev consistent hashing: every backend fills a
+// prime-sized lookup table following its own permutation, yielding near-perfect
+// balance, O(1) lookups and minimal disruption when the pool changes.
+type maglev struct {
+	backends []*pool.Backend
+	table    []int
+	key      KeyFunc
+}
+
+func NewMaglev(backends []*pool.Backend, tableSize int, key KeyFunc) *maglev {
+	return &maglev{
+		backends: backends,
+		table:    populateMaglev(backends, tableSize),
+		key:      key,
+	}
+}
+
+// populateMaglev builds the lookup table. Backends take turns claiming the next
+// free slot of their permutation; a backend takes one turn per unit of weight.
+func populateMaglev(backends []*pool.Backend, size int) []int {
+	n := len(backends)
+	if n == 0 || size <= 0 {
+		return nil
+	}
+
+	offsets := make([]uint64, n)
+	skips := make([]uint64, n)
+	next := make([]uint64, n)
+	for i, backend := range backends {
+		offsets[i] = hashString("offset:"+backend.Name()) % uint64(size)
+		skips[i] = hashString("skip:"+backend.Name())%uint64(size-1) + 1
+	}
+
+	table := make([]int, size)
+	for i := range table {
+		table[i] = -1
+	}
+
+	filled := 0
+	for filled < size {
+		for i, backend := range backends {
+			for range max(backend.Weight(), 1) {
+				// Find this backend's next preferred free slot
+				slot := (offsets[i] + next[i]*skips[i]) % uint64(size)
+				for table[slot] >= 0 {
+					next[i]++
+					slot = (offsets[i] + next[i]*skips[i]) % uint64(size)
+				}
+
+				table[slot] = i
+				next[i]++
+				filled++
+
+				if filled == size {
+					return table
+				}
+			}
+		}
+	}
+
+	return table
+}
+
+// Next routes without a request, see ringHash.Next
+func (m *maglev) Next() *pool.Backend {
+	return m.lookup(0)
+}
+
+func (m *maglev) NextFor(r *http.Request) *pool.Backend {
+	return m.lookup(hashString(m.key(r)))
+}
+
+// lookup returns the table entry for hash, or the next available backend
+// further along the table when that one cannot take requests.
+func (m *maglev) lookup(hash uint64) *pool.Backend {
+	size := len(m.table)
+	if size == 0 {
+		return nil
+	}
+
+	start := int(hash % uint64(size))
+	if backend := m.backends[m.table[start]]; isAvailable(backend) {
+		return backend
+	}
+
+	tried := make(map[int]bool)
+
+	for i := 0; i < size && len(tried) < len(m.backends); i++ {
+		idx := m.table[(start+i)%size]
+		if tried[idx] {
+			continue
+		}
+		tried[idx] = true
+
+		if isAvailable(m.backends[idx]) {
+			return m.backends[idx]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package balancer
+
+import (
+	"math/rand"
+
+	"github.com/Lucascluz/reverxy/internal/loadbalancer/pool"
+)
+
+// powerOfTwoChoices samples two random backends and picks the one with the lower
+// latency score. It avoids both the herd effect of always picking the global
+// minimum and the cost of scanning every backend.
+type powerOfTwoChoices struct {
+	backends []*pool.Backend
+}
+
+func NewPowerOfTwoChoices(backends []*pool.Backend) *powerOfTwoChoices {
+	return &powerOfTwoChoices{
+		backends: backends,
+	}
+}
+
+func (p2c *powerOfTwoChoices) Next() *pool.Backend {
+	n := len(p2c.backends)
+	if n == 0 {
+		return nil
+	}
+	if n == 1 {
+		return p2c.backends[0]
+	}
+
+	// Pick two distinct backends
+	i := rand.Intn(n)
+	j := rand.Intn(n - 1)
+	if j >= i {
+		j++
+	}
+
+	a, b := p2c.backends[i], p2c.backends[j]
+
+	// An unavailable candidate always loses
+	switch {
+	case !isAvailable(a):
+		return b
+	case !isAvailable(b):
+		return a
+	}
+
+	if latencyScore(b) < latencyScore(a) {
+		return b
+	}
+	return a
+}
+
+// latencyScore estimates how long a new request would wait on backend: its
+// in-flight requests (plus this one) times its average response time, inflated
+// while the backend is in slow start. Backends without samples score zero so
+// they get probed.
+func latencyScore(backend *pool.Backend) float64 {
+	return float64(backend.ActiveConns()+1) * float64(backend.AvgResponseTime()) / backend.SlowStartFactor()
+}
@@ -25,7 +25,7 @@ func (rw *randomWeight) Next() *pool.Backend {
 	}
 
 	// select a N random number of between 1 and half of total backends
-	randomN := rand.Intn(n/2) + 1
+	randomN := rand.Intn(max(n/2, 1)) + 1
 
 	// select N random backends and return the biggest weight one
 	var selected *pool.Backend
@@ -33,7 +33,7 @@ func (rw *randomWeight) Next() *pool.Backend {
 		idx := rand.Intn(n)
 		backend := rw.backends[idx]
 
-		if selected == nil || backend.Weight() > selected.Weight() {
+		if selected == nil || backend.EffectiveWeight() > selected.EffectiveWeight() {
 			selected = backend
 		}
 	}
@@ -0,0 +1,77 @@
+package balancer
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/Lucascluz/reverxy/internal/loadbalancer/pool"
+)
+
+// ringHash places virtualNodes points per unit of weight for every backend on a
+// hash ring. A key maps to the first point clockwise from its hash, so adding or
+// removing a backend only moves the keys adjacent to its points (~1/N).
+type ringHash struct {
+	backends []*pool.Backend
+	ring     []ringPoint
+	key      KeyFunc
+}
+
+type ringPoint struct {
+	hash    uint64
+	backend int
+}
+
+func NewRingHash(backends []*pool.Backend, virtualNodes int, key KeyFunc) *ringHash {
+	var ring []ringPoint
+	for i, backend := range backends {
+		points := virtualNodes * max(backend.Weight(), 1)
+		for v := range points {
+			ring = append(ring, ringPoint{
+				hash:    hashString(backend.Name() + "#" + strconv.Itoa(v)),
+				backend: i,
+			})
+		}
+	}
+
+	sort.Slice(ring, func(a, b int) bool {
+		return ring[a].hash < ring[b].hash
+	})
+
+	return &ringHash{
+		backends: backends,
+		ring:     ring,
+		key:      key,
+	}
+}
+
+// Next routes without a request, which only happens for callers unaware of
+// hashing; every such call maps to the same key.
+func (rh *ringHash) Next() *pool.Backend {
+	return rh.lookup(0)
+}
+
+func (rh *ringHash) NextFor(r *http.Request) *pool.Backend {
+	return rh.lookup(hashString(rh.key(r)))
+}
+
+// lookup walks clockwise from hash to the first available backend
+func (rh *ringHash) lookup(hash uint64) *pool.Backend {
+	n := len(rh.ring)
+	if n == 0 {
+		return nil
+	}
+
+	start := sort.Search(n, func(i int) bool {
+		return rh.ring[i].hash >= hash
+	})
+
+	for i := range n {
+		backend := rh.backends[rh.ring[(start+i)%n].backend]
+		if isAvailable(backend) {
+			return backend
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package balancer
 
 import (
+	"math/rand"
 	"sync/atomic"
 
 	"github.com/Lucascluz/reverxy/internal/loadbalancer/pool"
@@ -23,8 +24,19 @@ func (rr *roundRobin) Next() *pool.Backend {
 		return nil
 	}
 
-	val := rr.index.Add(1)
-	idx := (val - 1) % int32(n)
+	var candidate *pool.Backend
+	for range n {
+		val := rr.index.Add(1)
+		idx := (val - 1) % int32(n)
+		candidate = (rr.backends)[idx]
 
-	return (rr.backends)[idx]
+		// Backends in slow start take their turn with probability equal to
+		// their ramp factor; the others pass it on to the next backend
+		if factor := candidate.SlowStartFactor(); factor >= 1 || rand.Float64() < factor {
+			return candidate
+		}
+	}
+
+	// Every backend is ramping up and passed: serve rather than fail
+	return candidate
 }
@@ -0,0 +1,72 @@
+package balancer
+
+import (
+	"sync"
+
+	"github.com/Lucascluz/reverxy/internal/loadbalancer/pool"
+)
+
+// weightedRoundRobin implements nginx's smooth weighted round-robin: every pick
+// raises each backend's current weight by its weight, selects the highest and
+// lowers it by the total. Over sum(weights) picks each backend is chosen exactly
+// weight times, interleaved instead of in bursts (weights 5,1,1 give
+// a a b a c a a rather than a a a a a b c). Unavailable backends (unhealthy,
+// draining or at capacity) sit picks out and their weight leaves the total.
+type weightedRoundRobin struct {
+	mu       sync.Mutex
+	backends []*pool.Backend
+	current  []float64
+}
+
+func NewWeightedRoundRobin(backends []*pool.Backend) *weightedRoundRobin {
+	return &weightedRoundRobin{
+		backends: backends,
+		current:  make([]float64, len(backends)),
+	}
+}
+
+func (wrr *weightedRoundRobin) Next() *pool.Backend {
+	wrr.mu.Lock()
+	defer wrr.mu.Unlock()
+
+	n := len(wrr.backends)
+	if n == 0 {
+		return nil
+	}
+
+	total := 0.0
+	best := -1
+	for i, backend := range wrr.backends {
+		if !isAvailable(backend) {
+			continue
+		}
+
+		// Slow start lowers the weight of recovering backends
+		weight := backend.EffectiveWeight()
+		wrr.current[i] += weight
+		total += weight
+
+		if best == -1 || wrr.current[i] > wrr.current[best] {
+			best = i
+		}
+	}
+
+	if best == -1 {
+		return wrr.saturated()
+	}
+
+	wrr.current[best] -= total
+
+	return wrr.backends[best]
+}
+
+// saturated returns a healthy backend when none is available, so the caller
+// sees the pool at capacity rather than down
+func (wrr *weightedRoundRobin) saturated() *pool.Backend {
+	for _, backend := range wrr.backends {
+		if backend.IsAvailable() {
+			return backend
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+)
+
+// Pause before re-querying when a blocking query returned without a change
+const consulRetryDelay = time.Second
+
+// Consul discovers the passing instances of a service through blocking queries
+// on the health API, so membership changes arrive as soon as Consul sees them.
+// Service metadata becomes backend labels, and the weight is read from the
+// configured metadata key or else from the service's passing weight.
+type Consul struct {
+	cfg    config.DiscoveryConfig
+	client *http.Client
+	index  uint64
+}
+
+// consulEntry is the part of a /v1/health/service entry that is used
+type consulEntry struct {
+	Node struct {
+		Node       string
+		Address    string
+		Datacenter string
+	}
+	Service struct {
+		ID      string
+		Address string
+		Port    int
+		Tags    []string
+		Meta    map[string]string
+		Weights struct {
+			Passing int
+		}
+	}
+}
+
+func NewConsul(cfg config.DiscoveryConfig) *Consul {
+	return &Consul{
+		cfg: cfg,
+		// Consul adds up to wait/16 of jitter before answering a blocking query
+		client: &http.Client{Timeout: cfg.Consul.Wait + cfg.Consul.Wait/16 + 10*time.Second},
+	}
+}
+
+// Discover blocks until the service's instances change or the wait elapses
+func (c *Consul) Discover(ctx context.Context) ([]config.BackendConfig, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.queryURL(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if c.cfg.Consul.Token != "" {
+		req.Header.Set("X-Consul-Token", c.cfg.Consul.Token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("consul query: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, 0, fmt.Errorf("consul query: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var entries []consulEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("decode consul response: %w", err)
+	}
+
+	// Per the Consul docs, start over when the index goes backwards and never
+	// block on an index below 1
+	previous := c.index
+	index, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	switch {
+	case err != nil || index < c.index:
+		c.index = 0
+	case index < 1:
+		c.index = 1
+	default:
+		c.index = index
+	}
+
+	// Query again right away after a change; otherwise pause briefly so a
+	// query that returns early cannot turn into a busy loop
+	delay := time.Duration(0)
+	if c.index == previous || c.index == 0 {
+		delay = consulRetryDelay
+	}
+
+	return c.backends(entries), delay, nil
+}
+
+func (c *Consul) queryURL() string {
+	query := url.Values{}
+	query.Set("passing", "1")
+	query.Set("wait", fmt.Sprintf("%ds", int(c.cfg.Consul.Wait.Seconds())))
+
+	if c.index > 0 {
+		query.Set("index", strconv.FormatUint(c.index, 10))
+	}
+	if c.cfg.Consul.Datacenter != "" {
+		query.Set("dc", c.cfg.Consul.Datacenter)
+	}
+	for _, tag := range c.cfg.Consul.Tags {
+		query.Add("tag", tag)
+	}
+
+	return strings.TrimRight(c.cfg.Consul.Address, "/") + "/v1/health/service/" +
+		url.PathEscape(c.cfg.Consul.Service) + "?" + query.Encode()
+}
+
+func (c *Consul) backends(entries []consulEntry) []config.BackendConfig {
+	backends := make([]config.BackendConfig, 0, len(entries))
+
+	for _, entry := range entries {
+		// Older Consul versions ignore repeated tag parameters
+		if !hasTags(entry.Service.Tags, c.cfg.Consul.Tags) {
+			continue
+		}
+
+		address := entry.Service.Address
+		if address == "" {
+			address = entry.Node.Address
+		}
+
+		backend := backendConfig(c.cfg, entry.Node.Node+"/"+entry.Service.ID,
+			net.JoinHostPort(address, strconv.Itoa(entry.Service.Port)))
+
+		if weight, err := strconv.Atoi(entry.Service.Meta[c.cfg.Consul.WeightMeta]); err == nil && weight > 0 {
+			backend.Weight = weight
+		} else if entry.Service.Weights.Passing > 0 {
+			backend.Weight = entry.Service.Weights.Passing
+		}
+
+		backend.Labels = make(map[string]string, len(entry.Service.Meta)+2)
+		for key, value := range entry.Service.Meta {
+			backend.Labels[key] = value
+		}
+		backend.Labels["node"] = entry.Node.Node
+		if entry.Node.Datacenter != "" {
+			backend.Labels["datacenter"] = entry.Node.Datacenter
+		}
+
+		backends = append(backends, backend)
+	}
+
+	slices.SortFunc(backends, func(a, b config.BackendConfig) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return backends
+}
+
+func hasTags(tags, required []string) bool {
+	for _, tag := range required {
+		if !slices.Contains(tags, tag) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,164 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+)
+
+// consulInstance is a registered service instance with its check status
+type consulInstance struct {
+	node, id, address, status string
+	port, passingWeight       int
+	tags                      []string
+	meta                      map[string]string
+}
+
+// consulStub serves /v1/health/service like the Consul agent: only passing
+// instances when asked for, with X-Consul-Index taken from indexes in turn
+type consulStub struct {
+	t         *testing.T
+	instances []consulInstance
+	indexes   []string
+	queries   []string // Index parameter of each query
+}
+
+func (s *consulStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/health/service/api" || r.Header.Get("X-Consul-Token") != "secret" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	if len(s.queries) == len(s.indexes) {
+		s.t.Errorf("unexpected query %s", r.URL.RawQuery)
+		http.Error(w, "no more responses", http.StatusInternalServerError)
+		return
+	}
+
+	index := s.indexes[len(s.queries)]
+	s.queries = append(s.queries, r.URL.Query().Get("index"))
+
+	var entries []consulEntry
+	for _, instance := range s.instances {
+		if r.URL.Query().Get("passing") != "" && instance.status != "passing" {
+			continue
+		}
+
+		var entry consulEntry
+		entry.Node.Node = instance.node
+		entry.Node.Address = "10.0.0.100"
+		entry.Node.Datacenter = "dc1"
+		entry.Service.ID = instance.id
+		entry.Service.Address = instance.address
+		entry.Service.Port = instance.port
+		entry.Service.Tags = instance.tags
+		entry.Service.Meta = instance.meta
+		entry.Service.Weights.Passing = instance.passingWeight
+		entries = append(entries, entry)
+	}
+
+	if index != "" {
+		w.Header().Set("X-Consul-Index", index)
+	}
+	json.NewEncoder(w).Encode(entries)
+}
+
+func TestConsulDiscover(t *testing.T) {
+	stub := &consulStub{
+		t: t,
+		instances: []consulInstance{
+			{node: "n1", id: "api-1", address: "10.0.0.1", status: "passing", port: 8080, passingWeight: 1,
+				tags: []string{"web"}, meta: map[string]string{"weight": "7", "version": "v2"}},
+			{node: "n2", id: "api-2", status: "passing", port: 8080, passingWeight: 3, tags: []string{"web"}},
+			{node: "n3", id: "api-3", address: "10.0.0.3", status: "critical", port: 8080, tags: []string{"web"}},
+			// Returned by agents that ignore the tag parameter
+			{node: "n4", id: "api-4", address: "10.0.0.4", status: "passing", port: 8080},
+		},
+		indexes: []string{"10", "10", "5", "0", "12"},
+	}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	c := NewConsul(config.DiscoveryConfig{
+		Scheme: "http",
+		Consul: config.ConsulDiscoveryConfig{
+			Address:    server.URL,
+			Service:    "api",
+			Tags:       []string{"web"},
+			Token:      "secret",
+			Wait:       time.Second,
+			WeightMeta: "weight",
+		},
+	})
+
+	ctx := context.Background()
+
+	// First query: passing instances with the required tag
+	backends, delay, err := c.Discover(ctx)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if delay != 0 {
+		t.Fatalf("delay after a change = %v, want 0", delay)
+	}
+
+	want := []config.BackendConfig{
+		{Name: "n1/api-1", Url: "http://10.0.0.1:8080", Weight: 7},
+		{Name: "n2/api-2", Url: "http://10.0.0.100:8080", Weight: 3},
+	}
+	if !slices.EqualFunc(backends, want, func(a, b config.BackendConfig) bool {
+		return a.Name == b.Name && a.Url == b.Url && a.Weight == b.Weight
+	}) {
+		t.Fatalf("backends = %+v, want %+v", backends, want)
+	}
+	if labels := backends[0].Labels; labels["version"] != "v2" || labels["node"] != "n1" || labels["datacenter"] != "dc1" {
+		t.Fatalf("labels = %v", labels)
+	}
+
+	// Unchanged index: the wait elapsed, pause before asking again
+	if _, delay, _ = c.Discover(ctx); delay != consulRetryDelay {
+		t.Fatalf("delay without a change = %v, want %v", delay, consulRetryDelay)
+	}
+
+	// The index went backwards, so the next query starts over without one
+	if _, delay, _ = c.Discover(ctx); delay != consulRetryDelay {
+		t.Fatalf("delay after an index reset = %v, want %v", delay, consulRetryDelay)
+	}
+
+	// An index of 0 is never used to block; 1 is sent instead
+	c.Discover(ctx)
+	c.Discover(ctx)
+
+	if want := []string{"", "10", "10", "", "1"}; !slices.Equal(stub.queries, want) {
+		t.Fatalf("query indexes = %q, want %q", stub.queries, want)
+	}
+	if c.index != 12 {
+		t.Fatalf("index = %d, want 12", c.index)
+	}
+}
+
+func TestConsulDiscoverError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Consul-Index", strconv.Itoa(3))
+		http.Error(w, "ACL not found", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	c := NewConsul(config.DiscoveryConfig{
+		Scheme: "http",
+		Consul: config.ConsulDiscoveryConfig{Address: server.URL, Service: "api", Wait: time.Second},
+	})
+
+	if _, _, err := c.Discover(context.Background()); err == nil {
+		t.Fatal("Discover succeeded on an error status")
+	}
+	if c.index != 0 {
+		t.Fatalf("index = %d after a failed query, want 0", c.index)
+	}
+}
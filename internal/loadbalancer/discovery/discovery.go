@@ -0,0 +1,190 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+	"github.com/Lucascluz/reverxy/internal/loadbalancer/pool"
+	"github.com/Lucascluz/reverxy/internal/observability"
+)
+
+// Provider is a source of backends
+type Provider interface {
+	// Discover returns the current set of backends and the delay before it is
+	// called again, capped at the refresh interval. Watch-based providers block
+	// until the set changes and return zero to be called again right away.
+	Discover(ctx context.Context) ([]config.BackendConfig, time.Duration, error)
+}
+
+// Discovery keeps the pool in sync with a Provider. Discovered backends are
+// added next to the static ones from the config, which are always kept.
+type Discovery struct {
+	cfg      config.DiscoveryConfig
+	provider Provider
+	pool     *pool.Pool
+	static   []config.BackendConfig
+	log      *observability.Logger
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates the discovery configured for the pool, or returns nil when
+// discovery is disabled
+func New(cfg *config.PoolConfig, p *pool.Pool) (*Discovery, error) {
+	var provider Provider
+
+	switch cfg.Discovery.Type {
+	case "":
+		return nil, nil
+	case "dns":
+		dns, err := NewDNS(cfg.Discovery)
+		if err != nil {
+			return nil, err
+		}
+		provider = dns
+	case "file":
+		provider = NewFile(cfg.Discovery)
+	case "kubernetes":
+		kubernetes, err := NewKubernetes(cfg.Discovery)
+		if err != nil {
+			return nil, err
+		}
+		provider = kubernetes
+	case "consul":
+		provider = NewConsul(cfg.Discovery)
+	default:
+		return nil, fmt.Errorf("unknown discovery type %q", cfg.Discovery.Type)
+	}
+
+	return &Discovery{
+		cfg:      cfg.Discovery,
+		provider: provider,
+		pool:     p,
+		static:   cfg.Backends,
+		log:      observability.NewLogger("discovery"),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start populates the pool once, so the first health checks already see the
+// discovered backends, then keeps refreshing it in the background
+func (d *Discovery) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+
+	wait := d.refresh(ctx)
+
+	go func() {
+		defer close(d.done)
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-timer.C:
+				timer.Reset(d.refresh(ctx))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the refresh loop. The pool keeps its last membership.
+func (d *Discovery) Stop() {
+	if d.cancel == nil {
+		return
+	}
+
+	d.cancel()
+	<-d.done
+}
+
+// refresh queries the provider and updates the pool, returning the delay until
+// the next query. On errors the previous membership is kept, so a failing
+// source never empties the pool.
+func (d *Discovery) refresh(ctx context.Context) time.Duration {
+	discovered, ttl, err := d.provider.Discover(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			d.log.Errorf("keeping previous backends: %v", err)
+		}
+		return d.cfg.RefreshInterval
+	}
+
+	backends := make([]config.BackendConfig, 0, len(d.static)+len(discovered))
+	backends = append(backends, d.static...)
+
+	names := make(map[string]bool, len(backends))
+	for _, backend := range d.static {
+		names[backend.Name] = true
+	}
+
+	for _, backend := range discovered {
+		if names[backend.Name] {
+			continue
+		}
+		names[backend.Name] = true
+
+		backend.HealthCheck = withHealthCheck(backend.HealthCheck, d.cfg.HealthCheck)
+		if err := backend.ApplyDefaults(len(backends)); err != nil {
+			d.log.Errorf("skipping discovered backend: %v", err)
+			continue
+		}
+		backends = append(backends, backend)
+	}
+
+	d.pool.Replace(backends)
+
+	return min(ttl, d.cfg.RefreshInterval)
+}
+
+// withHealthCheck fills the health check settings a discovered backend leaves
+// out from the discovery source's health_check block. Providers may return the
+// same backends again, so their header maps are never modified.
+func withHealthCheck(check, defaults config.HealthCheckConfig) config.HealthCheckConfig {
+	if check.Method == "" {
+		check.Method = defaults.Method
+	}
+	if check.Host == "" {
+		check.Host = defaults.Host
+	}
+	if len(defaults.Headers) > 0 {
+		headers := maps.Clone(defaults.Headers)
+		maps.Copy(headers, check.Headers)
+		check.Headers = headers
+	}
+	if len(check.ExpectedStatus) == 0 {
+		check.ExpectedStatus = defaults.ExpectedStatus
+	}
+	if check.BodyContains == "" {
+		check.BodyContains = defaults.BodyContains
+	}
+	if check.BodyRegex == "" {
+		check.BodyRegex = defaults.BodyRegex
+	}
+	if check.JSONPath == "" {
+		check.JSONPath, check.JSONValue = defaults.JSONPath, defaults.JSONValue
+	}
+	if check.Port == 0 {
+		check.Port = defaults.Port
+	}
+	return check
+}
+
+// backendConfig builds a discovered backend from the configured defaults
+func backendConfig(cfg config.DiscoveryConfig, name, hostPort string) config.BackendConfig {
+	url := cfg.Scheme + "://" + hostPort
+	return config.BackendConfig{
+		Name:      name,
+		Url:       url,
+		HealthUrl: url + cfg.HealthPath,
+		Weight:    cfg.Weight,
+		MaxConns:  cfg.MaxConns,
+	}
+}
@@ -0,0 +1,362 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+)
+
+const resolvConf = "/etc/resolv.conf"
+
+// DNS discovers one backend per address a name resolves to. Unlike the
+// transport's own resolution, every address becomes a separate backend with its
+// own health and connection accounting, and record TTLs drive the refresh.
+type DNS struct {
+	cfg    config.DiscoveryConfig
+	name   string
+	server string
+}
+
+func NewDNS(cfg config.DiscoveryConfig) (*DNS, error) {
+	server := cfg.DNS.Resolver
+	if server == "" {
+		var err error
+		if server, err = systemResolver(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	name, err := dnsmessage.NewName(fqdn(cfg.DNS.Name))
+	if err != nil {
+		return nil, fmt.Errorf("invalid dns name %q: %w", cfg.DNS.Name, err)
+	}
+
+	return &DNS{
+		cfg:    cfg,
+		name:   name.String(),
+		server: server,
+	}, nil
+}
+
+func (d *DNS) Discover(ctx context.Context) ([]config.BackendConfig, time.Duration, error) {
+	var backends []config.BackendConfig
+	var ttl uint32
+	var err error
+
+	if d.cfg.DNS.RecordType == "SRV" {
+		backends, ttl, err = d.discoverSRV(ctx)
+	} else {
+		backends, ttl, err = d.discoverHosts(ctx)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// A name without records is treated as a lookup failure rather than an
+	// empty pool, since it is usually a transient DNS problem mid-deploy
+	if len(backends) == 0 {
+		return nil, 0, fmt.Errorf("no %s records for %s", d.cfg.DNS.RecordType, d.cfg.DNS.Name)
+	}
+
+	// Resolvers rotate record order; keep the pool order stable
+	slices.SortFunc(backends, func(a, b config.BackendConfig) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return backends, max(time.Duration(ttl)*time.Second, d.cfg.DNS.MinTTL), nil
+}
+
+// discoverHosts resolves A and/or AAAA records into backends on the configured port
+func (d *DNS) discoverHosts(ctx context.Context) ([]config.BackendConfig, uint32, error) {
+	addrs, ttl, err := d.lookupAddrs(ctx, d.name, d.cfg.DNS.RecordType)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	backends := make([]config.BackendConfig, 0, len(addrs))
+	for _, addr := range addrs {
+		hostPort := net.JoinHostPort(addr.String(), strconv.Itoa(d.cfg.DNS.Port))
+		backends = append(backends, backendConfig(d.cfg, hostPort, hostPort))
+	}
+
+	return backends, ttl, nil
+}
+
+// discoverSRV resolves SRV records, taking port, weight and priority from each
+// record. Target addresses come from the additional section when the server
+// includes them, otherwise they are looked up separately.
+func (d *DNS) discoverSRV(ctx context.Context) ([]config.BackendConfig, uint32, error) {
+	answers, additionals, err := d.query(ctx, d.name, dnsmessage.TypeSRV)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ttl := minTTL(answers)
+	known := addrsByName(additionals)
+	if len(known) > 0 {
+		ttl = min(ttl, minTTL(additionals))
+	}
+
+	var backends []config.BackendConfig
+	for _, answer := range answers {
+		srv, ok := answer.Body.(*dnsmessage.SRVResource)
+		if !ok {
+			continue
+		}
+
+		// A target of "." means the service is not offered at this name
+		target := srv.Target.String()
+		if target == "." {
+			continue
+		}
+
+		addrs := known[target]
+		if len(addrs) == 0 {
+			var targetTTL uint32
+			addrs, targetTTL, err = d.lookupAddrs(ctx, target, "A+AAAA")
+			if err != nil {
+				return nil, 0, fmt.Errorf("resolve SRV target %s: %w", target, err)
+			}
+			ttl = min(ttl, targetTTL)
+		}
+
+		for _, addr := range addrs {
+			hostPort := net.JoinHostPort(addr.String(), strconv.Itoa(int(srv.Port)))
+			backend := backendConfig(d.cfg, hostPort, hostPort)
+			backend.Weight = int(srv.Weight)
+			backend.Priority = int(srv.Priority)
+			backends = append(backends, backend)
+		}
+	}
+
+	return backends, ttl, nil
+}
+
+// lookupAddrs returns the addresses of name for recordType ("A", "AAAA" or
+// "A+AAAA") and the lowest TTL among them. With both families, a failed query
+// for one (e.g. a resolver that drops AAAA queries) only loses its addresses.
+func (d *DNS) lookupAddrs(ctx context.Context, name, recordType string) ([]netip.Addr, uint32, error) {
+	var types []dnsmessage.Type
+	switch recordType {
+	case "A":
+		types = []dnsmessage.Type{dnsmessage.TypeA}
+	case "AAAA":
+		types = []dnsmessage.Type{dnsmessage.TypeAAAA}
+	default:
+		types = []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA}
+	}
+
+	var all []dnsmessage.Resource
+	var errs []error
+	for _, qtype := range types {
+		answers, _, err := d.query(ctx, name, qtype)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		all = append(all, answers...)
+	}
+
+	if len(errs) > 0 && len(all) == 0 {
+		return nil, 0, errors.Join(errs...)
+	}
+
+	var addrs []netip.Addr
+	for _, found := range addrsByName(all) {
+		addrs = append(addrs, found...)
+	}
+
+	return addrs, minTTL(all), nil
+}
+
+// query sends a single question to the resolver over UDP, retrying over TCP
+// when the answer is truncated. A missing name yields no records, not an error.
+func (d *DNS) query(ctx context.Context, name string, qtype dnsmessage.Type) ([]dnsmessage.Resource, []dnsmessage.Resource, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.DNS.Timeout)
+	defer cancel()
+
+	id := uint16(rand.Uint32())
+	question, err := buildQuery(id, name, qtype)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response, err := exchange(ctx, "udp", d.server, question)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse dns response: %w", err)
+	}
+
+	if header.Truncated {
+		if response, err = exchange(ctx, "tcp", d.server, question); err != nil {
+			return nil, nil, err
+		}
+		if header, err = parser.Start(response); err != nil {
+			return nil, nil, fmt.Errorf("parse dns response: %w", err)
+		}
+	}
+
+	if header.ID != id {
+		return nil, nil, fmt.Errorf("dns response id mismatch")
+	}
+
+	switch header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("dns query for %s failed: %s", name, header.RCode)
+	}
+
+	if err := parser.SkipAllQuestions(); err != nil {
+		return nil, nil, fmt.Errorf("parse dns response: %w", err)
+	}
+
+	answers, err := parser.AllAnswers()
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse dns answers: %w", err)
+	}
+
+	// Additional records are optional; a malformed section only loses the hints
+	if err := parser.SkipAllAuthorities(); err != nil {
+		return answers, nil, nil
+	}
+	additionals, _ := parser.AllAdditionals()
+
+	return answers, additionals, nil
+}
+
+func buildQuery(id uint16, name string, qtype dnsmessage.Type) ([]byte, error) {
+	qname, err := dnsmessage.NewName(fqdn(name))
+	if err != nil {
+		return nil, fmt.Errorf("invalid dns name %q: %w", name, err)
+	}
+
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	if err := builder.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := builder.Question(dnsmessage.Question{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	return builder.Finish()
+}
+
+// exchange sends msg and reads one response; TCP messages are length-prefixed
+func exchange(ctx context.Context, network, server string, msg []byte) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, fmt.Errorf("dial resolver %s: %w", server, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "udp" {
+		if _, err := conn.Write(msg); err != nil {
+			return nil, fmt.Errorf("dns query: %w", err)
+		}
+
+		buf := make([]byte, 65535)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("dns query: %w", err)
+		}
+		return buf[:n], nil
+	}
+
+	framed := binary.BigEndian.AppendUint16(nil, uint16(len(msg)))
+	if _, err := conn.Write(append(framed, msg...)); err != nil {
+		return nil, fmt.Errorf("dns query: %w", err)
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, fmt.Errorf("dns query: %w", err)
+	}
+
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, fmt.Errorf("dns query: %w", err)
+	}
+	return buf, nil
+}
+
+// addrsByName collects the A and AAAA records of a section by owner name.
+// CNAME chains are followed implicitly by the recursive resolver.
+func addrsByName(resources []dnsmessage.Resource) map[string][]netip.Addr {
+	addrs := make(map[string][]netip.Addr)
+	for _, r := range resources {
+		name := r.Header.Name.String()
+		switch body := r.Body.(type) {
+		case *dnsmessage.AResource:
+			addrs[name] = append(addrs[name], netip.AddrFrom4(body.A))
+		case *dnsmessage.AAAAResource:
+			addrs[name] = append(addrs[name], netip.AddrFrom16(body.AAAA))
+		}
+	}
+	return addrs
+}
+
+func minTTL(resources []dnsmessage.Resource) uint32 {
+	var ttl uint32
+	for i, r := range resources {
+		if i == 0 || r.Header.TTL < ttl {
+			ttl = r.Header.TTL
+		}
+	}
+	return ttl
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// systemResolver returns the first nameserver from /etc/resolv.conf
+func systemResolver() (string, error) {
+	file, err := os.Open(resolvConf)
+	if err != nil {
+		return "", fmt.Errorf("no dns resolver configured: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return fields[1], nil
+		}
+	}
+
+	return "", fmt.Errorf("no nameserver in %s", resolvConf)
+}
@@ -0,0 +1,195 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+)
+
+// stubZone answers queries from fixed records. Names listed in failing
+// answer SERVFAIL for the given type, and SRV answers carry the addresses
+// found in additional as hints.
+type stubZone struct {
+	records    map[dnsmessage.Question][]dnsmessage.Resource
+	additional []dnsmessage.Resource
+	failing    map[dnsmessage.Question]bool
+}
+
+func question(name string, qtype dnsmessage.Type) dnsmessage.Question {
+	return dnsmessage.Question{Name: dnsmessage.MustNewName(name), Type: qtype, Class: dnsmessage.ClassINET}
+}
+
+func resourceHeader(name string, qtype dnsmessage.Type, ttl uint32) dnsmessage.ResourceHeader {
+	return dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: qtype, Class: dnsmessage.ClassINET, TTL: ttl}
+}
+
+func aRecord(name string, ttl uint32, ip [4]byte) dnsmessage.Resource {
+	return dnsmessage.Resource{Header: resourceHeader(name, dnsmessage.TypeA, ttl), Body: &dnsmessage.AResource{A: ip}}
+}
+
+func srvRecord(name string, ttl uint32, priority, weight, port uint16, target string) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: resourceHeader(name, dnsmessage.TypeSRV, ttl),
+		Body: &dnsmessage.SRVResource{
+			Priority: priority,
+			Weight:   weight,
+			Port:     port,
+			Target:   dnsmessage.MustNewName(target),
+		},
+	}
+}
+
+// serveStub answers UDP queries against zone until the test ends and returns
+// the server address
+func serveStub(t *testing.T, zone stubZone) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+				continue
+			}
+			q := query.Questions[0]
+
+			response := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionAvailable: true},
+				Questions: query.Questions,
+			}
+			switch {
+			case zone.failing[q]:
+				response.RCode = dnsmessage.RCodeServerFailure
+			case zone.records[q] == nil:
+				response.RCode = dnsmessage.RCodeNameError
+			default:
+				response.Answers = zone.records[q]
+				if q.Type == dnsmessage.TypeSRV {
+					response.Additionals = zone.additional
+				}
+			}
+
+			packed, err := response.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(packed, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func newTestDNS(t *testing.T, server string, dnsCfg config.DNSDiscoveryConfig) *DNS {
+	t.Helper()
+
+	dnsCfg.Resolver = server
+	dnsCfg.Timeout = time.Second
+
+	d, err := NewDNS(config.DiscoveryConfig{Scheme: "http", HealthPath: "/health", DNS: dnsCfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestDNSDiscoverSRV(t *testing.T) {
+	server := serveStub(t, stubZone{
+		records: map[dnsmessage.Question][]dnsmessage.Resource{
+			question("_http._tcp.api.test.", dnsmessage.TypeSRV): {
+				srvRecord("_http._tcp.api.test.", 300, 10, 5, 8080, "a.api.test."),
+				srvRecord("_http._tcp.api.test.", 300, 20, 1, 9090, "b.api.test."),
+				srvRecord("_http._tcp.api.test.", 300, 0, 0, 0, "."),
+			},
+			question("b.api.test.", dnsmessage.TypeA): {
+				aRecord("b.api.test.", 30, [4]byte{10, 0, 0, 2}),
+			},
+		},
+		additional: []dnsmessage.Resource{
+			aRecord("a.api.test.", 120, [4]byte{10, 0, 0, 1}),
+		},
+		// A resolver that fails AAAA queries must not hide the A records
+		failing: map[dnsmessage.Question]bool{
+			question("b.api.test.", dnsmessage.TypeAAAA): true,
+		},
+	})
+
+	d := newTestDNS(t, server, config.DNSDiscoveryConfig{Name: "_http._tcp.api.test", RecordType: "SRV"})
+
+	backends, ttl, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+
+	want := []config.BackendConfig{
+		{Name: "10.0.0.1:8080", Url: "http://10.0.0.1:8080", HealthUrl: "http://10.0.0.1:8080/health", Weight: 5, Priority: 10},
+		{Name: "10.0.0.2:9090", Url: "http://10.0.0.2:9090", HealthUrl: "http://10.0.0.2:9090/health", Weight: 1, Priority: 20},
+	}
+	if !slices.EqualFunc(backends, want, func(a, b config.BackendConfig) bool {
+		return a.Name == b.Name && a.Url == b.Url && a.HealthUrl == b.HealthUrl && a.Weight == b.Weight && a.Priority == b.Priority
+	}) {
+		t.Fatalf("backends = %+v, want %+v", backends, want)
+	}
+
+	// The shortest TTL among the SRV records and the addresses they resolve to
+	if ttl != 30*time.Second {
+		t.Fatalf("ttl = %v, want 30s", ttl)
+	}
+}
+
+func TestDNSDiscoverHosts(t *testing.T) {
+	server := serveStub(t, stubZone{
+		records: map[dnsmessage.Question][]dnsmessage.Resource{
+			question("api.test.", dnsmessage.TypeA): {
+				aRecord("api.test.", 60, [4]byte{10, 0, 0, 2}),
+				aRecord("api.test.", 60, [4]byte{10, 0, 0, 1}),
+			},
+		},
+		failing: map[dnsmessage.Question]bool{
+			question("api.test.", dnsmessage.TypeAAAA):  true,
+			question("down.test.", dnsmessage.TypeA):    true,
+			question("down.test.", dnsmessage.TypeAAAA): true,
+		},
+	})
+
+	d := newTestDNS(t, server, config.DNSDiscoveryConfig{Name: "api.test", RecordType: "A+AAAA", Port: 8080, MinTTL: 90 * time.Second})
+
+	backends, ttl, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover with a failing AAAA query: %v", err)
+	}
+
+	var names []string
+	for _, b := range backends {
+		names = append(names, b.Name)
+	}
+	if want := []string{"10.0.0.1:8080", "10.0.0.2:8080"}; !slices.Equal(names, want) {
+		t.Fatalf("backends = %v, want %v", names, want)
+	}
+	if ttl != 90*time.Second {
+		t.Fatalf("ttl = %v, want min_ttl 90s", ttl)
+	}
+
+	// Only the failure of every family fails the lookup
+	d = newTestDNS(t, server, config.DNSDiscoveryConfig{Name: "down.test", RecordType: "A+AAAA", Port: 8080})
+	if _, _, err := d.Discover(context.Background()); err == nil {
+		t.Fatal("Discover succeeded although every query failed")
+	}
+}
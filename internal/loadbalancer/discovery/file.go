@@ -0,0 +1,87 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+)
+
+// File discovers backends from a file maintained by deployment tooling, in the
+// style of Prometheus file_sd. The file holds a JSON or YAML list of backend
+// entries using the same fields as the static backends:
+//
+//	- name: api-1
+//	  url: http://10.0.0.1:8080
+//	  weight: 2
+//	  labels: {zone: eu-west-1a}
+//
+// The file is polled and only parsed again when its size or modification time
+// changes. An empty list removes every discovered backend; a file that cannot
+// be read or parsed keeps the previous ones.
+type File struct {
+	cfg config.DiscoveryConfig
+
+	modTime  time.Time
+	size     int64
+	backends []config.BackendConfig
+}
+
+func NewFile(cfg config.DiscoveryConfig) *File {
+	return &File{cfg: cfg}
+}
+
+func (f *File) Discover(ctx context.Context) ([]config.BackendConfig, time.Duration, error) {
+	info, err := os.Stat(f.cfg.File.Path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read backends file: %w", err)
+	}
+
+	if f.backends != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.backends, f.cfg.File.PollInterval, nil
+	}
+
+	data, err := os.ReadFile(f.cfg.File.Path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read backends file: %w", err)
+	}
+
+	// JSON is valid YAML, so one decoder handles both formats
+	var entries []config.BackendConfig
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, 0, fmt.Errorf("parse %s: %w", f.cfg.File.Path, err)
+	}
+
+	backends := make([]config.BackendConfig, 0, len(entries))
+	for i, entry := range entries {
+		if entry.Url == "" {
+			return nil, 0, fmt.Errorf("parse %s: backend %d has no url", f.cfg.File.Path, i)
+		}
+
+		// Fill in what the entry leaves out from the discovery defaults
+		if entry.Name == "" {
+			entry.Name = entry.Url
+		}
+		if entry.HealthUrl == "" {
+			entry.HealthUrl = f.cfg.HealthPath
+		}
+		if entry.Weight == 0 {
+			entry.Weight = f.cfg.Weight
+		}
+		if entry.MaxConns == 0 {
+			entry.MaxConns = f.cfg.MaxConns
+		}
+
+		backends = append(backends, entry)
+	}
+
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	f.backends = backends
+
+	return backends, f.cfg.File.PollInterval, nil
+}
@@ -0,0 +1,255 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+)
+
+// Namespace of the pod, mounted with its service account token
+const namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// Longest wait for the informer's initial list, which also delays the first
+// synchronous refresh at startup
+const kubernetesSyncTimeout = 5 * time.Second
+
+// Kubernetes discovers the endpoints of a Service by watching its
+// EndpointSlices. Ready endpoints become backends; terminating endpoints leave
+// the pool as soon as they are marked, so they receive no new requests while
+// the ones in flight complete. As kube-proxy does, endpoints that are
+// terminating but still serving are used when no endpoint is ready.
+type Kubernetes struct {
+	cfg       config.DiscoveryConfig
+	client    kubernetes.Interface
+	namespace string
+
+	informer cache.SharedIndexInformer
+	changed  chan struct{}
+	listed   bool
+}
+
+func NewKubernetes(cfg config.DiscoveryConfig) (*Kubernetes, error) {
+	var restConfig *rest.Config
+	var err error
+
+	if cfg.Kubernetes.Kubeconfig != "" {
+		restConfig, err = clientcmd.BuildConfigFromFlags("", cfg.Kubernetes.Kubeconfig)
+	} else {
+		restConfig, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes client config: %w", err)
+	}
+
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes client: %w", err)
+	}
+
+	return newKubernetes(cfg, client), nil
+}
+
+// newKubernetes builds the provider around an existing client
+func newKubernetes(cfg config.DiscoveryConfig, client kubernetes.Interface) *Kubernetes {
+	namespace := cfg.Kubernetes.Namespace
+	if namespace == "" {
+		namespace = podNamespace()
+	}
+
+	return &Kubernetes{
+		cfg:       cfg,
+		client:    client,
+		namespace: namespace,
+		changed:   make(chan struct{}, 1),
+	}
+}
+
+// Discover starts the watch on the first call and afterwards blocks until one
+// of the service's EndpointSlices changes
+func (k *Kubernetes) Discover(ctx context.Context) ([]config.BackendConfig, time.Duration, error) {
+	if k.informer == nil {
+		k.start(ctx)
+	} else if k.listed {
+		select {
+		case <-k.changed:
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+	}
+
+	// The watch keeps running on failure, so the next call only waits again
+	syncCtx, cancel := context.WithTimeout(ctx, min(kubernetesSyncTimeout, k.cfg.RefreshInterval))
+	defer cancel()
+
+	if !cache.WaitForCacheSync(syncCtx.Done(), k.informer.HasSynced) {
+		return nil, 0, fmt.Errorf("endpointslices of %s/%s not synced", k.namespace, k.cfg.Kubernetes.Service)
+	}
+
+	// The listing below already reflects any event queued so far
+	select {
+	case <-k.changed:
+	default:
+	}
+
+	endpointSlices := make([]*discoveryv1.EndpointSlice, 0)
+	for _, obj := range k.informer.GetStore().List() {
+		if slice, ok := obj.(*discoveryv1.EndpointSlice); ok {
+			endpointSlices = append(endpointSlices, slice)
+		}
+	}
+
+	k.listed = true
+	return k.backends(endpointSlices), 0, nil
+}
+
+// start runs an informer on the EndpointSlices labeled with the service name
+// for as long as ctx lives
+func (k *Kubernetes) start(ctx context.Context) {
+	selector := discoveryv1.LabelServiceName + "=" + k.cfg.Kubernetes.Service
+
+	factory := informers.NewSharedInformerFactoryWithOptions(k.client, 0,
+		informers.WithNamespace(k.namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = selector
+		}),
+	)
+
+	k.informer = factory.Discovery().V1().EndpointSlices().Informer()
+	k.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(any) { k.notify() },
+		UpdateFunc: func(any, any) { k.notify() },
+		DeleteFunc: func(any) { k.notify() },
+	})
+
+	factory.Start(ctx.Done())
+}
+
+// notify wakes a pending Discover; bursts of events collapse into one refresh
+func (k *Kubernetes) notify() {
+	select {
+	case k.changed <- struct{}{}:
+	default:
+	}
+}
+
+// backends maps the endpoints of slices to backends, one per address and port.
+// Endpoints may appear in several slices while they are being rebalanced.
+func (k *Kubernetes) backends(endpointSlices []*discoveryv1.EndpointSlice) []config.BackendConfig {
+	var ready, serving []config.BackendConfig
+	seen := make(map[string]bool)
+
+	for _, slice := range endpointSlices {
+		if slice.AddressType == discoveryv1.AddressTypeFQDN {
+			continue
+		}
+
+		port, ok := k.port(slice.Ports)
+		if !ok {
+			continue
+		}
+
+		for _, endpoint := range slice.Endpoints {
+			isReady := endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready
+			isServing := endpoint.Conditions.Serving != nil && *endpoint.Conditions.Serving
+			isTerminating := endpoint.Conditions.Terminating != nil && *endpoint.Conditions.Terminating
+
+			if !isReady && !(isServing && isTerminating) {
+				continue
+			}
+
+			for _, address := range endpoint.Addresses {
+				hostPort := net.JoinHostPort(address, strconv.Itoa(port))
+				if seen[hostPort] {
+					continue
+				}
+				seen[hostPort] = true
+
+				backend := backendConfig(k.cfg, hostPort, hostPort)
+				backend.Labels = endpointLabels(endpoint)
+
+				if isReady {
+					ready = append(ready, backend)
+				} else {
+					serving = append(serving, backend)
+				}
+			}
+		}
+	}
+
+	backends := ready
+	if len(backends) == 0 {
+		backends = serving
+	}
+
+	slices.SortFunc(backends, func(a, b config.BackendConfig) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return backends
+}
+
+// port finds the configured service port, by name or number, or the first one
+func (k *Kubernetes) port(ports []discoveryv1.EndpointPort) (int, bool) {
+	want := k.cfg.Kubernetes.Port
+
+	for _, p := range ports {
+		if p.Port == nil {
+			continue
+		}
+
+		name := ""
+		if p.Name != nil {
+			name = *p.Name
+		}
+
+		if want == "" || want == name || want == strconv.Itoa(int(*p.Port)) {
+			return int(*p.Port), true
+		}
+	}
+
+	return 0, false
+}
+
+func endpointLabels(endpoint discoveryv1.Endpoint) map[string]string {
+	labels := make(map[string]string)
+
+	if endpoint.TargetRef != nil && endpoint.TargetRef.Kind == "Pod" {
+		labels["pod"] = endpoint.TargetRef.Name
+	}
+	if endpoint.NodeName != nil {
+		labels["node"] = *endpoint.NodeName
+	}
+	if endpoint.Zone != nil {
+		labels["zone"] = *endpoint.Zone
+	}
+
+	return labels
+}
+
+// podNamespace returns the namespace the proxy runs in, or "default"
+func podNamespace() string {
+	data, err := os.ReadFile(namespaceFile)
+	if err != nil {
+		return "default"
+	}
+
+	if namespace := strings.TrimSpace(string(data)); namespace != "" {
+		return namespace
+	}
+	return "default"
+}
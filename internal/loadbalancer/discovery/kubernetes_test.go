@@ -0,0 +1,135 @@
+package discovery
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+)
+
+// endpointSlice builds a slice of the "api" service. The fake clientset keeps
+// resourceVersion as given, and informers treat updates that keep it as
+// resyncs, so each version of a slice carries its own.
+func endpointSlice(name, resourceVersion string, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+	portName := "http"
+	port := int32(8080)
+
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "shop",
+			ResourceVersion: resourceVersion,
+			Labels:          map[string]string{discoveryv1.LabelServiceName: "api"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       []discoveryv1.EndpointPort{{Name: &portName, Port: &port}},
+		Endpoints:   endpoints,
+	}
+}
+
+func endpoint(address string, ready, serving, terminating bool) discoveryv1.Endpoint {
+	return discoveryv1.Endpoint{
+		Addresses: []string{address},
+		Conditions: discoveryv1.EndpointConditions{
+			Ready:       &ready,
+			Serving:     &serving,
+			Terminating: &terminating,
+		},
+	}
+}
+
+func discoverNames(t *testing.T, ctx context.Context, k *Kubernetes) []string {
+	t.Helper()
+
+	backends, _, err := k.Discover(ctx)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+
+	names := make([]string, 0, len(backends))
+	for _, b := range backends {
+		names = append(names, b.Name)
+	}
+	return names
+}
+
+func TestKubernetesEndpointSlices(t *testing.T) {
+	// The informer runs for as long as the context of the first Discover
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := fake.NewSimpleClientset(endpointSlice("api-a", "1",
+		endpoint("10.0.0.1", true, true, false),
+		endpoint("10.0.0.2", false, false, false),
+		endpoint("10.0.0.3", false, true, true),
+	))
+
+	// The fake clientset drops events sent before the informer's watch is
+	// registered, so changes are only made once it is
+	watching := make(chan struct{})
+	client.PrependWatchReactor("endpointslices", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w, err := client.Tracker().Watch(action.GetResource(), action.GetNamespace())
+		if err != nil {
+			return false, nil, err
+		}
+		close(watching)
+		return true, w, nil
+	})
+
+	k := newKubernetes(config.DiscoveryConfig{
+		Scheme:          "http",
+		RefreshInterval: time.Minute,
+		Kubernetes:      config.KubernetesDiscoveryConfig{Service: "api", Namespace: "shop", Port: "http"},
+	}, client)
+
+	slicesClient := client.DiscoveryV1().EndpointSlices("shop")
+
+	// Only ready endpoints are used while there are any
+	if got, want := discoverNames(t, ctx, k), []string{"10.0.0.1:8080"}; !slices.Equal(got, want) {
+		t.Fatalf("initial backends = %v, want %v", got, want)
+	}
+
+	select {
+	case <-watching:
+	case <-time.After(5 * time.Second):
+		t.Fatal("informer never started watching")
+	}
+
+	// Once nothing is ready, terminating endpoints that still serve are used
+	_, err := slicesClient.Update(ctx, endpointSlice("api-a", "2",
+		endpoint("10.0.0.1", false, false, true),
+		endpoint("10.0.0.3", false, true, true),
+	), metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := discoverNames(t, ctx, k), []string{"10.0.0.3:8080"}; !slices.Equal(got, want) {
+		t.Fatalf("backends after update = %v, want %v", got, want)
+	}
+
+	// A ready endpoint in a new slice takes over again
+	_, err = slicesClient.Create(ctx, endpointSlice("api-b", "3",
+		endpoint("10.0.1.1", true, true, false),
+	), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := discoverNames(t, ctx, k), []string{"10.0.1.1:8080"}; !slices.Equal(got, want) {
+		t.Fatalf("backends after add = %v, want %v", got, want)
+	}
+
+	if err := slicesClient.Delete(ctx, "api-b", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := discoverNames(t, ctx, k), []string{"10.0.0.3:8080"}; !slices.Equal(got, want) {
+		t.Fatalf("backends after delete = %v, want %v", got, want)
+	}
+}
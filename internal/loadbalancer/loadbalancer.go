@@ -1,56 +1,154 @@
 package loadbalancer
 
 import (
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
 	"sync"
 	"sync/atomic"
 
 	"github.com/Lucascluz/reverxy/internal/config"
 	"github.com/Lucascluz/reverxy/internal/loadbalancer/balancer"
 	"github.com/Lucascluz/reverxy/internal/loadbalancer/pool"
+	"github.com/Lucascluz/reverxy/internal/ratelimiter"
 )
 
 type LoadBalancer struct {
 	mu   sync.Mutex
 	pool *pool.Pool
-
-	balancer Balancer
-	ready    atomic.Bool
+	cfg  *config.LoadBalancerConfig
+
+	tiers             []*tier
+	hashKey           balancer.KeyFunc
+	failoverThreshold float64
+	affinity          *affinity
+	queue             *waitQueue
+	ready             atomic.Bool
 }
 
 type Balancer interface {
 	Next() *pool.Backend
 }
 
-func NewLoadBalancer(cfg *config.LoadBalancerConfig) *LoadBalancer {
+// RequestBalancer is a Balancer whose choice may depend on the request, such as
+// the consistent-hash balancers. Plain balancers satisfy it via requestAgnostic.
+type RequestBalancer interface {
+	NextFor(r *http.Request) *pool.Backend
+}
+
+// requestAgnostic adapts a Balancer that ignores the request
+type requestAgnostic struct {
+	Balancer
+}
+
+func (ra requestAgnostic) NextFor(r *http.Request) *pool.Backend {
+	return ra.Next()
+}
+
+// NewLoadBalancer creates the pool and its balancers. The extractor resolves
+// client IPs for the consistent-hash balancers.
+func NewLoadBalancer(cfg *config.LoadBalancerConfig, extractor *ratelimiter.Extractor) (*LoadBalancer, error) {
 	// Create the pool with a callback that updates our readiness
 	pool := pool.NewPool(&cfg.Pool)
 
-	// Create the balancing strategy
-	balancer := newBalancingStrategy(pool.Backends(), cfg.Type)
+	// Create a balancing strategy per priority tier
+	hashKey := balancer.NewKeyFunc(cfg.Hash, extractor.Extract)
+	tiers, err := buildTiers(pool.Backends(), cfg, hashKey)
+	if err != nil {
+		return nil, err
+	}
 
-	return &LoadBalancer{
-		pool:   pool,
-		balancer: balancer,
-		ready: atomic.Bool{},
+	lb := &LoadBalancer{
+		pool:              pool,
+		cfg:               cfg,
+		tiers:             tiers,
+		hashKey:           hashKey,
+		failoverThreshold: cfg.FailoverThreshold,
+		affinity:          newAffinity(cfg.Sticky),
+		queue:             newWaitQueue(cfg.Queue),
+		ready:             atomic.Bool{},
+	}
+
+	// Freed connection slots go to queued requests first
+	if lb.queue != nil {
+		pool.OnRelease(lb.queue.wakeHead)
+	}
+
+	// Rebuild the tiers whenever backends join or leave the pool
+	pool.Subscribe(lb.rebuild)
+
+	return lb, nil
+}
+
+// rebuild swaps in balancers over the new backend set. Removed backends stop
+// being picked immediately; requests already holding them are unaffected.
+func (lb *LoadBalancer) rebuild(backends []*pool.Backend) {
+	tiers, err := buildTiers(backends, lb.cfg, lb.hashKey)
+	if err != nil {
+		// The strategy was validated at startup, so this cannot normally happen
+		log.Printf("load balancer: keeping previous backends: %v", err)
+		return
+	}
+
+	lb.mu.Lock()
+	lb.tiers = tiers
+	lb.mu.Unlock()
+}
+
+// Next picks a backend for r from the active priority tier and takes one of
+// its connection slots, which the caller releases with DecrementConnections.
+// When every backend is at max connections and the wait queue is enabled, it
+// waits for a slot to be freed.
+func (lb *LoadBalancer) Next(r *http.Request) (*pool.Backend, error) {
+	// Requests arriving while others wait queue behind them
+	if lb.queue == nil || lb.queue.empty() {
+		backend, err := lb.pick(r)
+		if lb.queue == nil || !errors.Is(err, errAtCapacity) {
+			return backend, err
+		}
 	}
+
+	return lb.queue.wait(r, func() (*pool.Backend, error) {
+		return lb.pick(r)
+	})
 }
 
-func (lb *LoadBalancer) Next() (*pool.Backend, error) {
+// pick selects an available backend and acquires a slot on it
+func (lb *LoadBalancer) pick(r *http.Request) (*pool.Backend, error) {
 	lb.mu.Lock()
 	defer lb.mu.Unlock()
 
-	backends := lb.pool.Backends()
-	maxTries := len(backends)
+	// Lower tiers only take traffic once the tiers above them are degraded
+	active := activeTier(lb.tiers, lb.failoverThreshold)
+	if active == nil {
+		lb.SetReady(false)
+		return nil, fmt.Errorf("no healthy backends available")
+	}
+
+	// Honor the client's session binding while that backend can take requests
+	if lb.affinity != nil {
+		if name, _, ok := lb.affinity.lookup(r); ok {
+			if backend, found := lb.pool.Backend(name); found && active.contains(backend) &&
+				backend.IsAvailable() && backend.TryAcquire() {
+				lb.SetReady(true)
+				return backend, nil
+			}
+		}
+	}
+
+	maxTries := len(active.backends)
+	atCapacity := false
 
 	for range maxTries {
-		backend := lb.balancer.Next()
+		backend := active.balancer.NextFor(r)
 
-		if backend == nil || !backend.IsHealthy() {
+		if backend == nil || !backend.IsAvailable() {
 			continue
 		}
 
-		if backend.IsAtCapacity() {
+		if !backend.TryAcquire() {
+			atCapacity = true
 			continue
 		}
 
@@ -58,10 +156,39 @@ func (lb *LoadBalancer) Next() (*pool.Backend, error) {
 		return backend, nil
 	}
 
+	// Random strategies can keep missing the one backend with a free slot, so
+	// sweep them all before giving up
+	for _, backend := range active.backends {
+		if !backend.IsAvailable() {
+			continue
+		}
+
+		if !backend.TryAcquire() {
+			atCapacity = true
+			continue
+		}
+
+		lb.SetReady(true)
+		return backend, nil
+	}
+
+	// Healthy but saturated backends keep the proxy ready
+	if atCapacity {
+		return nil, errAtCapacity
+	}
+
 	lb.SetReady(false)
 	return nil, fmt.Errorf("no healthy backends available")
 }
 
+// Bind pins the client to backend when sticky sessions are enabled, by setting
+// the affinity cookie or header on w. Must be called before the response header is written.
+func (lb *LoadBalancer) Bind(w http.ResponseWriter, r *http.Request, backend *pool.Backend) {
+	if lb.affinity != nil {
+		lb.affinity.bind(w, r, backend)
+	}
+}
+
 // IsReady returns true if the load balancer is ready to serve requests
 func (lb *LoadBalancer) IsReady() bool {
 	return lb.ready.Load()
@@ -77,15 +204,25 @@ func (lb *LoadBalancer) Pool() *pool.Pool {
 	return lb.pool
 }
 
-func newBalancingStrategy(backends []*pool.Backend, balancerType string) Balancer {
-	switch balancerType {
+func newBalancingStrategy(backends []*pool.Backend, cfg *config.LoadBalancerConfig, hashKey balancer.KeyFunc) (RequestBalancer, error) {
+	switch cfg.Type {
 	case "round-robin":
-		return balancer.NewRoundRobin(backends)
+		return requestAgnostic{balancer.NewRoundRobin(backends)}, nil
+	case "weighted-round-robin":
+		return requestAgnostic{balancer.NewWeightedRoundRobin(backends)}, nil
 	case "least-connections":
-		return balancer.NewLeastConns(backends)
+		return requestAgnostic{balancer.NewLeastConns(backends)}, nil
 	case "random-weight":
-		return balancer.NewRandomWeight(backends)
+		return requestAgnostic{balancer.NewRandomWeight(backends)}, nil
+	case "p2c":
+		return requestAgnostic{balancer.NewPowerOfTwoChoices(backends)}, nil
+	case "least-latency":
+		return requestAgnostic{balancer.NewLeastLatency(backends)}, nil
+	case "ring-hash":
+		return balancer.NewRingHash(backends, cfg.Hash.VirtualNodes, hashKey), nil
+	case "maglev":
+		return balancer.NewMaglev(backends, cfg.Hash.TableSize, hashKey), nil
 	default:
-		return balancer.NewRoundRobin(backends)
+		return nil, fmt.Errorf("unknown load balancer type %q", cfg.Type)
 	}
 }
@@ -1,27 +1,51 @@
 package pool
 
 import (
+	"maps"
+	"math"
 	"sync"
 	"time"
 
 	"github.com/Lucascluz/reverxy/internal/config"
 )
 
+// Weight of the newest sample in the response time EWMA
+const latencyDecay = 0.2
+
 type Backend struct {
 	name      string
 	url       string
 	healthUrl string
 	weight    int
 	maxConns  int
+	priority  int
+	backup    bool
+	labels    map[string]string
+	slowStart config.SlowStartConfig
+
+	healthCheck        config.HealthCheckConfig
+	healthyThreshold   int
+	unhealthyThreshold int
 
 	mu              sync.RWMutex
 	healthy         bool
+	healthySince    time.Time
+	trustFirstCheck bool
 	failureCount    int
+	successCount    int
 	activeConns     int
 	totalRequests   int
 	lastCheck       time.Time
 	backoffTime     time.Duration
 	avgResponseTime time.Duration
+
+	// Drain state: drained is closed when the drain ends
+	draining   bool
+	drained    chan struct{}
+	drainTimer *time.Timer
+
+	// Called after a connection slot is freed, set by the pool
+	onRelease func()
 }
 
 func NewBackend(cfg config.BackendConfig) *Backend {
@@ -31,6 +55,11 @@ func NewBackend(cfg config.BackendConfig) *Backend {
 		healthUrl: cfg.HealthUrl,
 		weight:    cfg.Weight,
 		maxConns:  cfg.MaxConns,
+		priority:  cfg.Priority,
+		backup:    cfg.Backup,
+		labels:    maps.Clone(cfg.Labels),
+
+		healthCheck: cfg.HealthCheck,
 
 		healthy:         false,
 		lastCheck:       time.Now().Add(-2 * time.Second), // Initialize to allow immediate health check
@@ -55,10 +84,32 @@ func (b *Backend) HealthUrl() string {
 	return b.healthUrl
 }
 
+// HealthCheck returns how the health URL is probed
+func (b *Backend) HealthCheck() config.HealthCheckConfig {
+	return b.healthCheck
+}
+
 func (b *Backend) Weight() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 	return b.weight
 }
 
+// Priority returns the backend's tier; lower values are preferred
+func (b *Backend) Priority() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.priority
+}
+
+// IsBackup reports whether the backend only takes traffic after every
+// non-backup tier is degraded
+func (b *Backend) IsBackup() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.backup
+}
+
 func (b *Backend) IsHealthy() bool {
 	// Lock to safely check health status
 	b.mu.RLock()
@@ -67,6 +118,15 @@ func (b *Backend) IsHealthy() bool {
 	return b.healthy
 }
 
+// IsAvailable reports whether the backend may receive new requests: it is
+// healthy and not draining
+func (b *Backend) IsAvailable() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.healthy && !b.draining
+}
+
 func (b *Backend) IsBackedOff() bool {
 	// Lock to safely check backoff and update LastCheck
 	b.mu.Lock()
@@ -76,6 +136,10 @@ func (b *Backend) IsBackedOff() bool {
 	return time.Now().Before(b.lastCheck.Add(b.backoffTime))
 }
 
+// UpdateHealth records a health check result. The health state only flips
+// after the configured number of consecutive results, except for the first
+// check of a backend configured at startup, which sets it directly so startup
+// is not delayed. Backends added at runtime always need the full threshold.
 func (b *Backend) UpdateHealth(success bool) {
 
 	// Lock to update health status
@@ -83,26 +147,66 @@ func (b *Backend) UpdateHealth(success bool) {
 	defer b.mu.Unlock()
 
 	b.lastCheck = time.Now()
+	first := b.trustFirstCheck
+	b.trustFirstCheck = false
 
 	if success {
 
+		b.failureCount = 0
+		b.successCount++
+
+		if !b.healthy && (first || b.successCount >= b.healthyThreshold) {
+			// Recovering backends ramp up from here (slow start)
+			b.healthySince = b.lastCheck
+			b.healthy = true
+		}
+
 		b.backoffTime = 1 * time.Second
-		b.healthy = true
 		return
 
 	} else {
 
 		// Failure case (either error or bad status code)
-		b.failureCount += 1
-		b.healthy = false
+		b.successCount = 0
+		b.failureCount++
+
+		if b.healthy && b.failureCount >= b.unhealthyThreshold {
+			b.healthy = false
+		}
 
-		// Exponential backoff with upper limit of 60 seconds
-		if b.backoffTime < 60*time.Second {
+		// Exponential backoff with upper limit of 60 seconds, once down; a
+		// healthy backend keeps being checked until it reaches the threshold
+		if !b.healthy && b.backoffTime < 60*time.Second {
 			b.backoffTime *= 2
 		}
 	}
 }
 
+// SlowStartFactor returns the fraction of its weight the backend should get,
+// ramping from the configured minimum to 1 over the slow start window after
+// it became healthy
+func (b *Backend) SlowStartFactor() float64 {
+	if b.slowStart.Window <= 0 {
+		return 1
+	}
+
+	b.mu.RLock()
+	elapsed := time.Since(b.healthySince)
+	b.mu.RUnlock()
+
+	if elapsed >= b.slowStart.Window {
+		return 1
+	}
+
+	progress := math.Pow(elapsed.Seconds()/b.slowStart.Window.Seconds(), 1/b.slowStart.Aggression)
+	return max(progress, b.slowStart.MinWeight)
+}
+
+// EffectiveWeight returns the weight adjusted for slow start
+func (b *Backend) EffectiveWeight() float64 {
+	return float64(b.Weight()) * b.SlowStartFactor()
+}
+
 // IsAtCapacity returns true if backend reached max connections
 func (b *Backend) IsAtCapacity() bool {
 	b.mu.RLock()
@@ -131,11 +235,160 @@ func (b *Backend) IncrementConnections() {
 	b.totalRequests++
 }
 
+// TryAcquire takes a connection slot if the backend is below max connections.
+// Unlike checking IsAtCapacity first, the check and the increment are atomic.
+func (b *Backend) TryAcquire() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.maxConns > 0 && b.activeConns >= b.maxConns {
+		return false
+	}
+
+	b.activeConns++
+	b.totalRequests++
+	return true
+}
+
 // DecrementConnections decrements the active connection count
 func (b *Backend) DecrementConnections() {
 	b.mu.Lock()
-	defer b.mu.Unlock()
 	if b.activeConns > 0 {
 		b.activeConns--
 	}
+	if b.draining && b.activeConns == 0 {
+		b.closeDrained()
+	}
+	onRelease := b.onRelease
+	b.mu.Unlock()
+
+	// Hand the freed slot to a queued request, if any
+	if onRelease != nil {
+		onRelease()
+	}
+}
+
+// RecordLatency folds a response time into the backend's exponentially
+// weighted moving average
+func (b *Backend) RecordLatency(latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.avgResponseTime == 0 {
+		b.avgResponseTime = latency
+		return
+	}
+
+	b.avgResponseTime = time.Duration(latencyDecay*float64(latency) + (1-latencyDecay)*float64(b.avgResponseTime))
+}
+
+// AvgResponseTime returns the EWMA of response times, zero before the first sample
+func (b *Backend) AvgResponseTime() time.Duration {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.avgResponseTime
+}
+
+// Labels returns a copy of the metadata attached to the backend, such as the
+// labels reported by service discovery
+func (b *Backend) Labels() map[string]string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return maps.Clone(b.labels)
+}
+
+// update applies the settings of cfg that can change without replacing the
+// backend, and reports whether any did
+func (b *Backend) update(cfg config.BackendConfig) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	changed := b.weight != cfg.Weight || b.maxConns != cfg.MaxConns ||
+		b.priority != cfg.Priority || b.backup != cfg.Backup
+
+	b.weight = cfg.Weight
+	b.maxConns = cfg.MaxConns
+	b.priority = cfg.Priority
+	b.backup = cfg.Backup
+	b.labels = maps.Clone(cfg.Labels)
+
+	return changed
+}
+
+// Drain stops new requests from being sent to the backend while the ones in
+// flight complete. The returned channel is closed when the drain ends: once the
+// backend has no active connections, when timeout expires (zero waits
+// indefinitely) or when Resume cancels it. Health checks keep running, and
+// draining an already draining backend returns the same channel.
+func (b *Backend) Drain(timeout time.Duration) <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.draining {
+		return b.drained
+	}
+
+	b.draining = true
+	b.drained = make(chan struct{})
+
+	if b.activeConns == 0 {
+		b.closeDrained()
+		return b.drained
+	}
+
+	if timeout > 0 {
+		drained := b.drained
+		b.drainTimer = time.AfterFunc(timeout, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if b.drained == drained {
+				b.closeDrained()
+			}
+		})
+	}
+
+	return b.drained
+}
+
+// Resume ends draining, so the backend receives new requests again
+func (b *Backend) Resume() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.draining {
+		return
+	}
+
+	b.closeDrained()
+	b.draining = false
+	b.drained = nil
+}
+
+// IsDraining reports whether the backend is being drained
+func (b *Backend) IsDraining() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.draining
+}
+
+// Drained returns the channel closed when draining completes, or nil if the
+// backend is not draining
+func (b *Backend) Drained() <-chan struct{} {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.drained
+}
+
+// closeDrained signals the end of the drain. Callers must hold the lock.
+func (b *Backend) closeDrained() {
+	select {
+	case <-b.drained:
+	default:
+		close(b.drained)
+	}
+
+	if b.drainTimer != nil {
+		b.drainTimer.Stop()
+		b.drainTimer = nil
+	}
 }
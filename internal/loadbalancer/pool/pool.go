@@ -1,44 +1,76 @@
 package pool
 
 import (
+	"fmt"
+	"reflect"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Lucascluz/reverxy/internal/config"
+	"github.com/Lucascluz/reverxy/internal/observability"
 )
 
 type Pool struct {
-	mu sync.RWMutex
-	backends []*Backend
+	mu           sync.RWMutex
+	backends     []*Backend
+	slowStart    config.SlowStartConfig
+	health       config.HealthCheckerConfig
+	drainTimeout time.Duration
+	subscribers  []func(backends []*Backend)
+	notifyMu     sync.Mutex
+	onRelease    atomic.Pointer[func()]
+	log          *observability.Logger
 }
 
 func NewPool(cfg *config.PoolConfig) *Pool {
 
-	backends := make([]*Backend, len(cfg.Backends))
-
-	for i, backendCfg := range cfg.Backends {
-		backends[i] = NewBackend(backendCfg)
+	pool := &Pool{
+		backends:     make([]*Backend, 0, len(cfg.Backends)),
+		slowStart:    cfg.SlowStart,
+		health:       cfg.HealthChecker,
+		drainTimeout: cfg.DrainTimeout,
+		mu:           sync.RWMutex{},
+		log:          observability.NewLogger("pool"),
 	}
 
-	pool := &Pool{
-		backends: backends,
-		mu:       sync.RWMutex{},
+	// Only the backends present at startup trust their first health check
+	for _, backendCfg := range cfg.Backends {
+		backend := pool.newBackend(backendCfg)
+		backend.trustFirstCheck = true
+		pool.backends = append(pool.backends, backend)
 	}
 
 	return pool
 }
 
+func (p *Pool) newBackend(cfg config.BackendConfig) *Backend {
+	backend := NewBackend(cfg)
+	backend.slowStart = p.slowStart
+	backend.healthyThreshold = p.health.HealthyThreshold
+	backend.unhealthyThreshold = p.health.UnhealthyThreshold
+	backend.onRelease = p.released
+
+	// Backends can be kept in maintenance from the config
+	if cfg.Drain {
+		backend.Drain(p.drainTimeout)
+	}
+	return backend
+}
+
 func (p *Pool) IsReady() bool {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	// Pool is ready if at least one backend is healthy
+	// Pool is ready if at least one backend is healthy and not draining
 	// This prevents the proxy from going not-ready if a single backend fails
 	if len(p.backends) == 0 {
 		return false
 	}
 
 	for _, backend := range p.backends {
-		if backend.IsHealthy() {
+		if backend.IsAvailable() {
 			return true
 		}
 	}
@@ -54,3 +86,247 @@ func (p *Pool) Backends() []*Backend {
 	copy(backends, p.backends)
 	return backends
 }
+
+// Backend returns the backend with the given name
+func (p *Pool) Backend(name string) (*Backend, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, backend := range p.backends {
+		if backend.Name() == name {
+			return backend, true
+		}
+	}
+	return nil, false
+}
+
+// OnRelease sets fn to be called whenever a request releases a connection slot
+// on any backend of the pool
+func (p *Pool) OnRelease(fn func()) {
+	p.onRelease.Store(&fn)
+}
+
+func (p *Pool) released() {
+	if fn := p.onRelease.Load(); fn != nil {
+		(*fn)()
+	}
+}
+
+// Subscribe registers fn to be called with the new backend set after every
+// membership change. Callbacks run synchronously, outside the pool lock, one
+// change at a time and in order, so the last call always carries the current
+// set. They must not change the pool membership themselves.
+func (p *Pool) Subscribe(fn func(backends []*Backend)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subscribers = append(p.subscribers, fn)
+}
+
+// Add creates a backend from cfg and adds it to the pool. The backend starts
+// unhealthy and only receives traffic once healthy_threshold consecutive
+// health checks succeed.
+func (p *Pool) Add(cfg config.BackendConfig) (*Backend, error) {
+	p.mu.Lock()
+
+	if p.indexOf(cfg.Name) >= 0 {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("backend %s already exists", cfg.Name)
+	}
+
+	backend := p.newBackend(cfg)
+	p.backends = append(p.backends, backend)
+
+	p.mu.Unlock()
+	p.notify()
+
+	return backend, nil
+}
+
+// Remove takes a backend out of the pool at once. It stops receiving new
+// requests while requests already in flight run to completion; the returned
+// backend is left draining so they can be followed through Drained.
+func (p *Pool) Remove(name string) (*Backend, error) {
+	p.mu.Lock()
+
+	i := p.indexOf(name)
+	if i < 0 {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("backend %s not found", name)
+	}
+
+	backend := p.backends[i]
+	p.backends = append(p.backends[:i:i], p.backends[i+1:]...)
+
+	p.mu.Unlock()
+
+	backend.Drain(p.drainTimeout)
+	p.notify()
+
+	return backend, nil
+}
+
+// Drain puts the named backend in drain mode, keeping it in the pool. A zero
+// timeout uses the pool's drain timeout.
+func (p *Pool) Drain(name string, timeout time.Duration) (*Backend, error) {
+	backend, _, err := p.drain(name, timeout)
+	return backend, err
+}
+
+// drain is Drain, also returning the channel closed when this drain ends
+func (p *Pool) drain(name string, timeout time.Duration) (*Backend, <-chan struct{}, error) {
+	backend, ok := p.Backend(name)
+	if !ok {
+		return nil, nil, fmt.Errorf("backend %s not found", name)
+	}
+
+	if timeout == 0 {
+		timeout = p.drainTimeout
+	}
+
+	wasDraining := backend.IsDraining()
+	drained := backend.Drain(timeout)
+
+	if !wasDraining {
+		p.log.Infof("draining backend %s (%d active connections)", name, backend.ActiveConns())
+		go p.watchDrain(backend, drained)
+	}
+
+	return backend, drained, nil
+}
+
+// Resume takes the named backend out of drain mode
+func (p *Pool) Resume(name string) (*Backend, error) {
+	backend, ok := p.Backend(name)
+	if !ok {
+		return nil, fmt.Errorf("backend %s not found", name)
+	}
+
+	backend.Resume()
+	return backend, nil
+}
+
+// Retire drains the named backend and removes it from the pool once drained,
+// so its progress stays observable meanwhile. Resuming it cancels the removal.
+func (p *Pool) Retire(name string, timeout time.Duration) (*Backend, error) {
+	backend, drained, err := p.drain(name, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-drained
+
+		// Resume closes the channel too, and a later drain replaces it
+		if backend.Drained() != drained {
+			return
+		}
+
+		// Remove this very backend, not one that replaced it meanwhile
+		p.mu.Lock()
+		i := slices.Index(p.backends, backend)
+		if i >= 0 {
+			p.backends = append(p.backends[:i:i], p.backends[i+1:]...)
+		}
+		p.mu.Unlock()
+
+		if i >= 0 {
+			p.log.Infof("removed drained backend %s", backend.Name())
+			p.notify()
+		}
+	}()
+
+	return backend, nil
+}
+
+// watchDrain logs how a drain ended
+func (p *Pool) watchDrain(backend *Backend, drained <-chan struct{}) {
+	<-drained
+
+	switch conns := backend.ActiveConns(); {
+	case conns == 0:
+		p.log.Infof("backend %s drained", backend.Name())
+	case !backend.IsDraining():
+		p.log.Infof("backend %s resumed", backend.Name())
+	default:
+		p.log.Infof("backend %s drain timed out with %d active connections", backend.Name(), conns)
+	}
+}
+
+// Replace reconciles the pool with cfgs, matching backends by name. Backends
+// whose URLs and health check are unchanged keep their health and connection
+// state and only get their settings updated; the others are added, swapped or
+// removed gracefully.
+func (p *Pool) Replace(cfgs []config.BackendConfig) {
+	p.mu.Lock()
+
+	current := make(map[string]*Backend, len(p.backends))
+	for _, backend := range p.backends {
+		current[backend.Name()] = backend
+	}
+
+	changed := len(cfgs) != len(p.backends)
+	backends := make([]*Backend, 0, len(cfgs))
+
+	for _, cfg := range cfgs {
+		existing, ok := current[cfg.Name]
+		if ok && existing.Url() == cfg.Url && existing.HealthUrl() == cfg.HealthUrl &&
+			reflect.DeepEqual(existing.HealthCheck(), cfg.HealthCheck) {
+			changed = existing.update(cfg) || changed
+			if cfg.Drain {
+				existing.Drain(p.drainTimeout)
+			}
+			backends = append(backends, existing)
+			continue
+		}
+
+		backends = append(backends, p.newBackend(cfg))
+		changed = true
+	}
+
+	// Backends that are gone finish their requests; mark them for observers
+	kept := make(map[*Backend]bool, len(backends))
+	for _, backend := range backends {
+		kept[backend] = true
+	}
+	for _, backend := range p.backends {
+		if !kept[backend] {
+			backend.Drain(p.drainTimeout)
+		}
+	}
+
+	p.backends = backends
+
+	p.mu.Unlock()
+
+	if changed {
+		p.notify()
+	}
+}
+
+// indexOf returns the position of the named backend. Callers must hold the lock.
+func (p *Pool) indexOf(name string) int {
+	for i, backend := range p.backends {
+		if backend.Name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// notify hands the current backend set to the subscribers. Concurrent changes
+// are serialized from snapshot to last callback, so an older set can never be
+// delivered after a newer one.
+func (p *Pool) notify() {
+	p.notifyMu.Lock()
+	defer p.notifyMu.Unlock()
+
+	p.mu.RLock()
+	subscribers := make([]func(backends []*Backend), len(p.subscribers))
+	copy(subscribers, p.subscribers)
+	p.mu.RUnlock()
+
+	backends := p.Backends()
+	for _, fn := range subscribers {
+		fn(backends)
+	}
+}
@@ -0,0 +1,166 @@
+package loadbalancer
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+	"github.com/Lucascluz/reverxy/internal/loadbalancer/pool"
+)
+
+var (
+	errAtCapacity   = errors.New("all backends at max connections")
+	errQueueFull    = errors.New("wait queue full")
+	errQueueTimeout = errors.New("timed out waiting for a backend")
+)
+
+// waitQueue holds requests while every backend is at max connections. Each
+// freed slot wakes the request at the head of the queue, which then competes
+// for a backend again; new requests queue behind waiting ones.
+type waitQueue struct {
+	maxSize        int
+	timeout        time.Duration
+	priorityHeader string
+
+	mu      sync.Mutex
+	waiters []*waiter
+	seq     uint64
+}
+
+type waiter struct {
+	priority int
+	seq      uint64
+	wake     chan struct{}
+}
+
+func newWaitQueue(cfg config.QueueConfig) *waitQueue {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	q := &waitQueue{
+		maxSize: cfg.MaxSize,
+		timeout: cfg.Timeout,
+	}
+	if cfg.Mode == "priority" {
+		q.priorityHeader = cfg.PriorityHeader
+	}
+	return q
+}
+
+// empty reports whether no request is waiting
+func (q *waitQueue) empty() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiters) == 0
+}
+
+// wait queues r until pick acquires a backend for it, the queue timeout
+// expires or the client goes away
+func (q *waitQueue) wait(r *http.Request, pick func() (*pool.Backend, error)) (*pool.Backend, error) {
+	w := &waiter{
+		priority: q.priority(r),
+		wake:     make(chan struct{}, 1),
+	}
+
+	if !q.push(w) {
+		return nil, errQueueFull
+	}
+
+	timer := time.NewTimer(q.timeout)
+	defer timer.Stop()
+
+	// A slot may have been freed before the request was queued
+	q.wakeHead()
+
+	for {
+		select {
+		case <-w.wake:
+			backend, err := pick()
+			if errors.Is(err, errAtCapacity) {
+				continue
+			}
+
+			// Leave the queue and let the next request try for any other free slot
+			q.remove(w)
+			q.wakeHead()
+			return backend, err
+
+		case <-timer.C:
+			q.remove(w)
+			q.wakeHead()
+			return nil, errQueueTimeout
+
+		case <-r.Context().Done():
+			q.remove(w)
+			q.wakeHead()
+			return nil, r.Context().Err()
+		}
+	}
+}
+
+// wakeHead signals the first waiting request
+func (q *waitQueue) wakeHead() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.waiters) == 0 {
+		return
+	}
+
+	select {
+	case q.waiters[0].wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *waitQueue) push(w *waiter) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.waiters) >= q.maxSize {
+		return false
+	}
+
+	q.seq++
+	w.seq = q.seq
+
+	// Higher priority first, arrival order within a priority
+	i := sort.Search(len(q.waiters), func(i int) bool {
+		return q.waiters[i].priority < w.priority
+	})
+	q.waiters = append(q.waiters, nil)
+	copy(q.waiters[i+1:], q.waiters[i:])
+	q.waiters[i] = w
+
+	return true
+}
+
+func (q *waitQueue) remove(w *waiter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, candidate := range q.waiters {
+		if candidate == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// priority reads the request's priority header; FIFO queues use 0 for all
+func (q *waitQueue) priority(r *http.Request) int {
+	if q.priorityHeader == "" {
+		return 0
+	}
+
+	priority, err := strconv.Atoi(r.Header.Get(q.priorityHeader))
+	if err != nil {
+		return 0
+	}
+	return priority
+}
@@ -0,0 +1,101 @@
+package loadbalancer
+
+import (
+	"sort"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+	"github.com/Lucascluz/reverxy/internal/loadbalancer/balancer"
+	"github.com/Lucascluz/reverxy/internal/loadbalancer/pool"
+)
+
+// tier is a priority group of backends with its own balancing strategy. Lower
+// priorities are preferred and backup backends form the last tiers.
+type tier struct {
+	priority int
+	backup   bool
+	backends []*pool.Backend
+	balancer RequestBalancer
+}
+
+// buildTiers groups backends by (backup, priority) in order of preference
+func buildTiers(backends []*pool.Backend, cfg *config.LoadBalancerConfig, hashKey balancer.KeyFunc) ([]*tier, error) {
+	type tierKey struct {
+		backup   bool
+		priority int
+	}
+
+	groups := make(map[tierKey][]*pool.Backend)
+	for _, backend := range backends {
+		key := tierKey{backup: backend.IsBackup(), priority: backend.Priority()}
+		groups[key] = append(groups[key], backend)
+	}
+
+	tiers := make([]*tier, 0, len(groups))
+	for key, members := range groups {
+		balancer, err := newBalancingStrategy(members, cfg, hashKey)
+		if err != nil {
+			return nil, err
+		}
+
+		tiers = append(tiers, &tier{
+			priority: key.priority,
+			backup:   key.backup,
+			backends: members,
+			balancer: balancer,
+		})
+	}
+
+	sort.Slice(tiers, func(i, j int) bool {
+		if tiers[i].backup != tiers[j].backup {
+			return !tiers[i].backup
+		}
+		return tiers[i].priority < tiers[j].priority
+	})
+
+	return tiers, nil
+}
+
+// healthyFraction returns the share of the tier's backends that are available,
+// i.e. healthy and not draining
+func (t *tier) healthyFraction() float64 {
+	if len(t.backends) == 0 {
+		return 0
+	}
+
+	healthy := 0
+	for _, backend := range t.backends {
+		if backend.IsAvailable() {
+			healthy++
+		}
+	}
+
+	return float64(healthy) / float64(len(t.backends))
+}
+
+func (t *tier) contains(backend *pool.Backend) bool {
+	for _, member := range t.backends {
+		if member == backend {
+			return true
+		}
+	}
+	return false
+}
+
+// activeTier returns the first tier whose healthy fraction reaches threshold.
+// If every tier is degraded, the most preferred tier with a healthy backend is
+// used so traffic still flows.
+func activeTier(tiers []*tier, threshold float64) *tier {
+	var fallback *tier
+
+	for _, t := range tiers {
+		fraction := t.healthyFraction()
+		if fraction >= threshold && fraction > 0 {
+			return t
+		}
+		if fallback == nil && fraction > 0 {
+			fallback = t
+		}
+	}
+
+	return fallback
+}
@@ -2,6 +2,7 @@ package observability
 
 import (
 	"fmt"
+	"math/rand/v2"
 	"net"
 	"net/http"
 	"os"
@@ -13,17 +14,38 @@ import (
 type HealthAware interface {
 	Name() string
 	HealthUrl() string
+	HealthCheck() config.HealthCheckConfig
 	IsBackedOff() bool
 	UpdateHealth(success bool)
 }
 
+// HealthTargets lists the backends to check. It is called once per round, so
+// backends added to or removed from the pool are picked up automatically.
+type HealthTargets func() []HealthAware
+
 type HealthChecker struct {
 	maxConcurrentChecks int
+	interval            time.Duration
 	client              *http.Client
-	ticker              *time.Ticker
 	stop                chan struct{}
 }
 
+// checkState is the schedule of one backend
+type checkState struct {
+	target  HealthAware
+	probe   *healthProbe
+	next    time.Time
+	running bool
+}
+
+const (
+	// How often the scheduler looks for due checks
+	scheduleTick = 100 * time.Millisecond
+
+	// Checks are spread by up to this fraction of the interval either way
+	checkJitter = 0.1
+)
+
 func NewHealthChecker(cfg *config.HealthCheckerConfig) *HealthChecker {
 
 	// Defensive defaults: fallback to config package defaults when tests left values zero
@@ -36,13 +58,14 @@ func NewHealthChecker(cfg *config.HealthCheckerConfig) *HealthChecker {
 	} else {
 		interval = cfg.Interval
 		timeout = cfg.Timeout
+		maxConcurrentChecks = cfg.MaxConcurrentChecks
 		if interval <= 0 {
 			interval = config.DefaultInterval
 		}
 		if timeout <= 0 {
 			timeout = config.DefaultTimeout
 		}
-		if cfg.MaxConcurrentChecks <= 0 {
+		if maxConcurrentChecks <= 0 {
 			maxConcurrentChecks = config.DefaultMaxConcurrentChecks
 		}
 	}
@@ -65,84 +88,157 @@ func NewHealthChecker(cfg *config.HealthCheckerConfig) *HealthChecker {
 
 	return &HealthChecker{
 		maxConcurrentChecks: maxConcurrentChecks,
+		interval:            interval,
 		client:              client,
-		ticker:              time.NewTicker(interval),
 		stop:                make(chan struct{}),
 	}
 }
 
-func (hc *HealthChecker) Start(backends []HealthAware, updateReady func()) {
-
-	fmt.Fprintf(os.Stderr, "\n[HEALTH] Starting checks for %d backends\n\n", len(backends))
-
-	// Run initial health checks immediately before waiting for ticker
-	doHealthChecks := func() {
-
-		os.Stderr.Sync()
-
-		// Run health checks synchronously for now to ensure they complete
-		for _, b := range backends {
-
-			os.Stderr.Sync()
-			healthCheck(hc.client, b)
-
-			os.Stderr.Sync()
+// Start checks every backend returned by targets on its own schedule, running
+// at most maxConcurrentChecks checks at a time. A slow or timed-out check only
+// delays its own backend. New backends are checked right away and removed
+// ones are dropped; updateReady runs after every completed check.
+func (hc *HealthChecker) Start(targets HealthTargets, updateReady func()) {
+
+	fmt.Fprintf(os.Stderr, "\n[HEALTH] Starting checks for %d backends (max %d concurrent)\n\n",
+		len(targets()), hc.maxConcurrentChecks)
+
+	ticker := time.NewTicker(scheduleTick)
+	defer ticker.Stop()
+
+	workers := make(chan struct{}, hc.maxConcurrentChecks)
+	done := make(chan *checkState)
+	schedule := make(map[string]*checkState)
+
+	dispatch := func() {
+		now := time.Now()
+		current := make(map[string]bool)
+
+		for _, target := range targets() {
+			name := target.Name()
+			current[name] = true
+
+			// Backends new to the pool, or replaced under the same name, are checked at once
+			state, ok := schedule[name]
+			if !ok || state.target != target {
+				state = &checkState{target: target, probe: probeFor(target), next: now}
+				schedule[name] = state
+			}
+
+			if state.running || now.Before(state.next) {
+				continue
+			}
+
+			// Due checks wait for a free worker on a later tick
+			select {
+			case workers <- struct{}{}:
+			default:
+				continue
+			}
+
+			state.running = true
+			go func() {
+				healthCheck(hc.client, state.target, state.probe)
+				<-workers
+
+				select {
+				case done <- state:
+				case <-hc.stop:
+				}
+			}()
 		}
 
-		os.Stderr.Sync()
-
-		// Update proxy readyness during health check
-		if updateReady != nil {
-			updateReady()
+		for name := range schedule {
+			if !current[name] {
+				delete(schedule, name)
+			}
 		}
 	}
 
-	// Execute immediate health check
-	doHealthChecks()
+	// Execute immediate health checks
+	dispatch()
 
 	for {
 		select {
-		case <-hc.ticker.C:
-			doHealthChecks()
+		case <-ticker.C:
+			dispatch()
+
+		case state := <-done:
+			state.running = false
+			state.next = time.Now().Add(hc.jitter())
+
+			// Update proxy readyness during health check
+			if updateReady != nil {
+				updateReady()
+			}
 
 		case <-hc.stop:
-			hc.ticker.Stop()
 			return
 		}
 	}
 }
 
+// jitter returns the interval spread randomly by checkJitter, so backends
+// added together drift apart instead of being probed in bursts
+func (hc *HealthChecker) jitter() time.Duration {
+	spread := float64(hc.interval) * checkJitter
+	return hc.interval + time.Duration((rand.Float64()*2-1)*spread)
+}
+
 func (hc *HealthChecker) Stop() {
 	close(hc.stop)
 }
 
-func healthCheck(client *http.Client, backend HealthAware) {
+// probeFor parses the backend's health check definition. Definitions are
+// validated with the config, so falling back to the default is a last resort.
+func probeFor(target HealthAware) *healthProbe {
+	probe, err := newHealthProbe(target.HealthCheck())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[HEALTH] %s has an invalid health check, using defaults: %v\n", target.Name(), err)
+		probe, _ = newHealthProbe(config.HealthCheckConfig{})
+	}
+	return probe
+}
+
+func healthCheck(client *http.Client, backend HealthAware, probe *healthProbe) {
 
 	// If backend is backed off, abort current health check
 	if backend.IsBackedOff() {
 		return
 	}
 
-	// Health check request
-	resp, err := client.Get(backend.HealthUrl())
+	status, err := runProbe(client, backend.HealthUrl(), probe)
+
+	if err == nil {
+		fmt.Fprintf(os.Stderr, "[HEALTH] %s is HEALTHY (status %d)\n", backend.Name(), status)
+	} else {
+		fmt.Fprintf(os.Stderr, "[HEALTH] %s FAILED: %v\n", backend.Name(), err)
+	}
+
+	backend.UpdateHealth(err == nil)
+}
 
-	// Close body if we got a response
-	if resp != nil && resp.Body != nil {
-		defer resp.Body.Close()
+// runProbe performs one health check request and verifies the response,
+// returning its status code
+func runProbe(client *http.Client, url string, probe *healthProbe) (int, error) {
+	req, err := probe.request(url)
+	if err != nil {
+		return 0, err
 	}
 
-	// Success case
-	success := (err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300)
+	// Health check request
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
 
-	if success {
-		fmt.Fprintf(os.Stderr, "[HEALTH] %s is HEALTHY (status %d)\n", backend.Name(), resp.StatusCode)
-	} else {
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "[HEALTH] %s FAILED: %v\n", backend.Name(), err)
-		} else {
-			fmt.Fprintf(os.Stderr, "[HEALTH] %s FAILED: status %d\n", backend.Name(), resp.StatusCode)
+	var body []byte
+	if probe.needsBody() {
+		if body, err = readHealthBody(resp.Body); err != nil {
+			return resp.StatusCode, fmt.Errorf("read body: %w", err)
 		}
 	}
 
-	backend.UpdateHealth(success)
+	return resp.StatusCode, probe.verify(resp.StatusCode, body)
 }
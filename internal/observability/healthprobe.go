@@ -0,0 +1,166 @@
+package observability
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+)
+
+// Health check bodies are only read up to this size
+const maxHealthBody = 64 << 10
+
+// healthProbe is a backend's health check definition, parsed once
+type healthProbe struct {
+	method   string
+	host     string
+	headers  map[string]string
+	statuses [][2]int
+	contains string
+	regex    *regexp.Regexp
+	jsonPath []string
+	jsonWant string
+}
+
+func newHealthProbe(cfg config.HealthCheckConfig) (*healthProbe, error) {
+	probe := &healthProbe{
+		method:   cfg.Method,
+		host:     cfg.Host,
+		headers:  cfg.Headers,
+		contains: cfg.BodyContains,
+		jsonWant: cfg.JSONValue,
+	}
+
+	if probe.method == "" {
+		probe.method = http.MethodGet
+	}
+
+	statuses := cfg.ExpectedStatus
+	if len(statuses) == 0 {
+		statuses = []string{"2xx"}
+	}
+	for _, status := range statuses {
+		lo, hi, err := config.ParseStatusRange(status)
+		if err != nil {
+			return nil, err
+		}
+		probe.statuses = append(probe.statuses, [2]int{lo, hi})
+	}
+
+	if cfg.BodyRegex != "" {
+		regex, err := regexp.Compile(cfg.BodyRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body_regex: %w", err)
+		}
+		probe.regex = regex
+	}
+
+	if cfg.JSONPath != "" {
+		probe.jsonPath = strings.Split(cfg.JSONPath, ".")
+	}
+
+	return probe, nil
+}
+
+// request builds the health check request for url
+func (p *healthProbe) request(url string) (*http.Request, error) {
+	req, err := http.NewRequest(p.method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range p.headers {
+		req.Header.Set(name, value)
+	}
+	if p.host != "" {
+		req.Host = p.host
+	}
+
+	return req, nil
+}
+
+// needsBody reports whether verify inspects the response body
+func (p *healthProbe) needsBody() bool {
+	return p.contains != "" || p.regex != nil || p.jsonPath != nil
+}
+
+// verify checks the response status and body against the definition
+func (p *healthProbe) verify(status int, body []byte) error {
+	expected := false
+	for _, r := range p.statuses {
+		if status >= r[0] && status <= r[1] {
+			expected = true
+			break
+		}
+	}
+	if !expected {
+		return fmt.Errorf("unexpected status %d", status)
+	}
+
+	if p.contains != "" && !strings.Contains(string(body), p.contains) {
+		return fmt.Errorf("body does not contain %q", p.contains)
+	}
+
+	if p.regex != nil && !p.regex.Match(body) {
+		return fmt.Errorf("body does not match %q", p.regex)
+	}
+
+	if p.jsonPath != nil {
+		return p.verifyJSON(body)
+	}
+
+	return nil
+}
+
+func (p *healthProbe) verifyJSON(body []byte) error {
+	path := strings.Join(p.jsonPath, ".")
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("body is not JSON: %w", err)
+	}
+
+	for _, key := range p.jsonPath {
+		switch node := value.(type) {
+		case map[string]any:
+			child, ok := node[key]
+			if !ok {
+				return fmt.Errorf("json path %s not found", path)
+			}
+			value = child
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return fmt.Errorf("json path %s not found", path)
+			}
+			value = node[i]
+		default:
+			return fmt.Errorf("json path %s not found", path)
+		}
+	}
+
+	if p.jsonWant == "" {
+		return nil
+	}
+
+	// Scalars compare by their text, so "true" and "200" match as expected
+	got := fmt.Sprint(value)
+	if s, ok := value.(string); ok {
+		got = s
+	}
+	if got != p.jsonWant {
+		return fmt.Errorf("json path %s is %q, want %q", path, got, p.jsonWant)
+	}
+
+	return nil
+}
+
+// readHealthBody reads at most maxHealthBody bytes of the body
+func readHealthBody(body io.Reader) ([]byte, error) {
+	return io.ReadAll(io.LimitReader(body, maxHealthBody))
+}
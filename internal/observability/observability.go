@@ -17,11 +17,11 @@ type Observability struct {
 // It requires:
 // - config: The application configuration
 // - readyAware: An object that implements ReadyAware interface (typically the LoadBalancer)
-// - backends: A slice of HealthAware backends to monitor
+// - backends: A function listing the HealthAware backends to monitor
 func NewObservability(
 	cfg *config.Config,
 	readyAware ReadyAware,
-	backends []HealthAware,
+	backends HealthTargets,
 ) (*Observability, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("config cannot be nil")
@@ -69,16 +69,13 @@ func (o *Observability) HealthChecker() *HealthChecker {
 }
 
 // StartHealthChecks starts the health checking routine with the provided backends
-// and a callback function that is invoked when health status changes
-func (o *Observability) StartHealthChecks(backends []HealthAware, onReadyChanged func()) error {
+// and a callback function that is invoked when health status changes. The
+// backend list is re-read every round so pool membership changes are followed.
+func (o *Observability) StartHealthChecks(backends HealthTargets, onReadyChanged func()) error {
 	if backends == nil {
 		return fmt.Errorf("backends cannot be nil")
 	}
 
-	if len(backends) == 0 {
-		return fmt.Errorf("at least one backend must be provided")
-	}
-
 	// Start health checker in background goroutine
 	go o.healthChecker.Start(backends, onReadyChanged)
 
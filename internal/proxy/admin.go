@@ -0,0 +1,198 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Lucascluz/reverxy/internal/loadbalancer/pool"
+)
+
+// AdminHandler returns the authenticated admin API. It is meant to be mounted
+// on the internal probe server, never on the public proxy port.
+func (s *Setup) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /admin/cache/purge", s.proxy.handlePurge)
+	mux.HandleFunc("POST /admin/cache/warmup", s.warmer.handleStart)
+	mux.HandleFunc("GET /admin/cache/warmup", s.warmer.handleStatus)
+	mux.HandleFunc("GET /admin/backends", s.proxy.handleBackends)
+	mux.HandleFunc("POST /admin/backends/{name}/drain", s.proxy.handleDrain)
+	mux.HandleFunc("POST /admin/backends/{name}/resume", s.proxy.handleResume)
+	mux.HandleFunc("DELETE /admin/backends/{name}", s.proxy.handleRetire)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, s.cfg.Admin.Token) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// handlePurge purges by any combination of `url` (exact, or prefix with a
+// trailing "*"), `prefix` and `tag` query parameters, each repeatable. An
+// absolute `url` only purges its host when cache keys include the host.
+func (p *Proxy) handlePurge(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if !query.Has("url") && !query.Has("prefix") && !query.Has("tag") {
+		http.Error(w, "one of url, prefix or tag is required", http.StatusBadRequest)
+		return
+	}
+
+	purged := 0
+	for _, value := range query["url"] {
+		host, uri := "", value
+		if u, err := url.Parse(value); err == nil && u.Host != "" {
+			host, uri = u.Host, u.RequestURI()
+		}
+		n, err := p.Purge(host, uri)
+		purged += n
+		if err != nil {
+			purgeError(w, purged, err)
+			return
+		}
+	}
+	for _, prefix := range query["prefix"] {
+		n, err := p.PurgePrefix(prefix)
+		purged += n
+		if err != nil {
+			purgeError(w, purged, err)
+			return
+		}
+	}
+	purged += p.PurgeTags(query["tag"]...)
+
+	writeJSON(w, http.StatusOK, map[string]int{"purged": purged})
+}
+
+// backendStatus is the admin view of a backend
+type backendStatus struct {
+	Name        string            `json:"name"`
+	URL         string            `json:"url"`
+	Healthy     bool              `json:"healthy"`
+	Draining    bool              `json:"draining"`
+	Drained     bool              `json:"drained"`
+	ActiveConns int               `json:"active_conns"`
+	Weight      int               `json:"weight"`
+	Priority    int               `json:"priority"`
+	Backup      bool              `json:"backup"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+func newBackendStatus(backend *pool.Backend) backendStatus {
+	status := backendStatus{
+		Name:        backend.Name(),
+		URL:         backend.Url(),
+		Healthy:     backend.IsHealthy(),
+		Draining:    backend.IsDraining(),
+		ActiveConns: backend.ActiveConns(),
+		Weight:      backend.Weight(),
+		Priority:    backend.Priority(),
+		Backup:      backend.IsBackup(),
+		Labels:      backend.Labels(),
+	}
+
+	if drained := backend.Drained(); drained != nil {
+		select {
+		case <-drained:
+			status.Drained = true
+		default:
+		}
+	}
+
+	return status
+}
+
+func (p *Proxy) handleBackends(w http.ResponseWriter, r *http.Request) {
+	backends := p.loadBalancer.Pool().Backends()
+
+	statuses := make([]backendStatus, 0, len(backends))
+	for _, backend := range backends {
+		statuses = append(statuses, newBackendStatus(backend))
+	}
+
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// handleDrain stops sending new requests to a backend. The optional `timeout`
+// query parameter bounds how long the drain waits for open requests.
+func (p *Proxy) handleDrain(w http.ResponseWriter, r *http.Request) {
+	p.drainBackend(w, r, p.loadBalancer.Pool().Drain)
+}
+
+// handleRetire drains a backend and removes it from the pool once drained
+func (p *Proxy) handleRetire(w http.ResponseWriter, r *http.Request) {
+	p.drainBackend(w, r, p.loadBalancer.Pool().Retire)
+}
+
+func (p *Proxy) drainBackend(w http.ResponseWriter, r *http.Request, drain func(string, time.Duration) (*pool.Backend, error)) {
+	var timeout time.Duration
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		var err error
+		if timeout, err = time.ParseDuration(value); err != nil || timeout < 0 {
+			http.Error(w, "invalid timeout", http.StatusBadRequest)
+			return
+		}
+	}
+
+	backend, err := drain(r.PathValue("name"), timeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, newBackendStatus(backend))
+}
+
+func (p *Proxy) handleResume(w http.ResponseWriter, r *http.Request) {
+	backend, err := p.loadBalancer.Pool().Resume(r.PathValue("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newBackendStatus(backend))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// handleStart starts a warm-up of the URLs in the request body (one per line),
+// or of the configured sources when the body is empty. Progress is available
+// from handleStatus while the run continues in the background.
+func (w *Warmer) handleStart(rw http.ResponseWriter, r *http.Request) {
+	urls, err := parseURLList(http.MaxBytesReader(rw, r.Body, 10<<20))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(urls) == 0 {
+		if urls, err = w.LoadURLs(); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if len(urls) == 0 {
+		http.Error(rw, "no URLs to warm", http.StatusBadRequest)
+		return
+	}
+
+	if err := w.Start(urls); err != nil {
+		http.Error(rw, err.Error(), http.StatusConflict)
+		return
+	}
+
+	writeJSON(rw, http.StatusAccepted, w.Status())
+}
+
+func (w *Warmer) handleStatus(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, http.StatusOK, w.Status())
+}
@@ -3,29 +3,26 @@ package proxy
 import (
 	"fmt"
 	"net/http"
-	"strconv"
+	"slices"
 	"strings"
 	"time"
 )
 
+// Responses to unsafe methods (POST, PATCH, ...) are never stored: they could only
+// be reused for identical unsafe requests, which must always reach the backend.
 var methods = map[string]bool{
-
-	// default
 	"GET":  true,
 	"HEAD": true,
-
-	// conditional
-	"POST":  true,
-	"PATCH": true,
 }
 
+// 206 is deliberately absent: partial responses are never stored, range
+// requests are answered from the complete 200 body instead.
 var codes = map[int]bool{
 
 	// default
 	200: true,
 	203: true,
 	204: true,
-	206: true,
 	300: true,
 	301: true,
 	308: true,
@@ -91,85 +88,69 @@ func (p *Proxy) tryCachingResponse(r *http.Request, statusCode int, headers http
 	}
 
 	// [6] Does response meet ANY freshness/cacheability requirements?
-	var contains bool
-	var explicitFreshness bool
-	var expiresAt time.Time
-
-	//     a) Response contains Expires header
-	expires := headers.Get("Expires")
-	if expires != "" {
-		parsedTime, err := time.Parse(time.RFC1123, expires)
-		if err == nil {
-			expiresAt = parsedTime
-			contains = true
-			explicitFreshness = true
-		}
-	}
+	//     Explicit freshness (s-maxage, max-age, Expires) is capped at max_age;
+	//     otherwise codes cacheable by default and public responses get
+	//     heuristic freshness from Last-Modified, or default_ttl.
+	directives := parseCacheControl(cacheControl)
+	ttl, cacheable := freshnessLifetime(statusCode, headers, directives, p.limitsFor(r.URL.Path), time.Now())
 
-	//     b) Response contains Cache-Control: max-age
-	if cacheControl != "" && strings.Contains(cacheControl, "max-age") {
-		contains = true
-		explicitFreshness = true
-
-		parts := strings.Split(cacheControl, "max-age=")
-		if len(parts) > 1 {
-			maxAge, err := strconv.Atoi(strings.TrimSpace(strings.Split(parts[1], ",")[0]))
-			if err == nil && maxAge > 0 {
-				expiresAt = time.Now().Add(time.Duration(maxAge) * time.Second)
-			}
-		}
+	// NONE TRUE → DO NOT STORE (no freshness info, not cacheable by default)
+	if !cacheable {
+		return false, "No freshness info, nor cacheable by default"
 	}
 
-	//     c) Response contains Cache-Control: s-maxage (for shared cache)
-	if cacheControl != "" && strings.Contains(cacheControl, "s-maxage") {
-		contains = true
-		explicitFreshness = true
+	// Already stale responses would never be served
+	if ttl <= 0 {
+		return false, "Already stale"
+	}
 
-		parts := strings.Split(cacheControl, "s-maxage=")
-		if len(parts) > 1 {
-			sMaxAge, err := strconv.Atoi(strings.TrimSpace(strings.Split(parts[1], ",")[0]))
-			if err == nil && sMaxAge > 0 {
-				expiresAt = time.Now().Add(time.Duration(sMaxAge) * time.Second)
-			}
-		}
+	// A response varying on "*" can never be matched by a later request
+	vary, matchable := parseVary(headers)
+	if !matchable {
+		return false, "Vary: *"
 	}
 
-	//     d) Response contains Cache-Control: public
-	if cacheControl != "" && strings.Contains(cacheControl, "public") {
-		contains = true
+	// Encoding variants are keyed by the coding the proxy negotiates
+	if slices.Contains(vary, "Accept-Encoding") && !p.compressor.storable(r, headers) {
+		return false, "Content-Encoding not negotiated"
 	}
 
-	//     e) Response has a status code cacheable by default (see section 2)
-	if codes[statusCode] {
-		contains = true
+	// [7] STORE RESPONSE
+	err := p.storeResponse(r, statusCode, headers, body, vary, ttl)
+	if err != nil {
+		return false, fmt.Sprintf("Cache error: %s", err.Error())
 	}
 
-	// NONE TRUE → DO NOT STORE (no freshness info, not cacheable by default)
-	if !contains {
-		return false, "No freshness info, nor cacheable by default"
+	return true, "STORED"
+}
+
+// mayStore reports whether the response to r could be stored, judging from
+// the request alone: a cached GET without credentials or no-store, on a path
+// whose max_age allows storing
+func (p *Proxy) mayStore(r *http.Request) bool {
+	if p.cache == nil || r.Method != "GET" {
+		return false
 	}
 
-	// [7] Special method checks:
-	// Method is POST or PATCH?
-	if r.Method == "POST" || r.Method == "PATCH" {
-		// Does response have explicit freshness (Expires, max-age, s-maxage)?
-		if !explicitFreshness {
-			return false, "No explicit freshness"
-		}
+	if r.Header.Get("Authorization") != "" {
+		return false
 	}
 
-	// [8] STORE RESPONSE
-	err := p.storeResponse(r.Method, r.URL.RequestURI(), statusCode, headers, body, expiresAt)
-	if err != nil {
-		return false, fmt.Sprintf("Cache error: %s", err.Error())
+	if _, noStore := parseCacheControl(r.Header.Get("Cache-Control"))["no-store"]; noStore {
+		return false
 	}
 
-	return true, "STORED"
+	return p.limitsFor(r.URL.Path).maxAge > 0
 }
 
 func (p *Proxy) tryServingCachedResponse(r *http.Request) (result bool, resp *CachedResponse) {
 
-	cachedResp, found := p.getResponse(r.Method, r.URL.String(), r.Header)
+	// Only safe requests may be answered without contacting the backend
+	if !methods[r.Method] {
+		return false, nil
+	}
+
+	cachedResp, found := p.getResponse(r)
 	if !found {
 		return false, nil
 	}
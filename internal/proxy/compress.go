@@ -0,0 +1,246 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressor applies transparent compression to backend responses. Compressed
+// bodies go into the cache as separate variants (the response varies on
+// Accept-Encoding), so hits never recompress.
+type compressor struct {
+	algorithms   []string
+	contentTypes map[string]bool
+	minSize      int
+
+	gzipPool   sync.Pool
+	brotliPool sync.Pool
+	zstd       *zstd.Encoder
+}
+
+func newCompressor(cfg config.CompressionConfig) *compressor {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	contentTypes := make(map[string]bool, len(cfg.ContentTypes))
+	for _, contentType := range cfg.ContentTypes {
+		contentTypes[strings.ToLower(contentType)] = true
+	}
+
+	// A nil writer with default options never fails to build
+	zstdEncoder, _ := zstd.NewWriter(nil)
+
+	return &compressor{
+		algorithms:   cfg.Algorithms,
+		contentTypes: contentTypes,
+		minSize:      cfg.MinSize,
+		gzipPool: sync.Pool{New: func() any {
+			return gzip.NewWriter(io.Discard)
+		}},
+		brotliPool: sync.Pool{New: func() any {
+			return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression)
+		}},
+		zstd: zstdEncoder,
+	}
+}
+
+// apply compresses body in the encoding negotiated with r when the response is
+// eligible, updating headers to match. Responses the backend already encoded
+// are passed through untouched. HEAD responses have no body to compress but
+// get the same Vary as a GET.
+func (c *compressor) apply(r *http.Request, statusCode int, headers http.Header, body []byte) []byte {
+	if c == nil || (r.Method != "GET" && r.Method != "HEAD") || statusCode != http.StatusOK {
+		return body
+	}
+
+	if encoding := headers.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return body
+	}
+
+	if _, noTransform := parseCacheControl(headers.Get("Cache-Control"))["no-transform"]; noTransform {
+		return body
+	}
+
+	// A HEAD response announces the size of the body a GET would return
+	size := len(body)
+	if r.Method == "HEAD" {
+		if length, err := strconv.Atoi(headers.Get("Content-Length")); err == nil {
+			size = length
+		} else {
+			size = c.minSize
+		}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(headers.Get("Content-Type"))
+	if err != nil || !c.contentTypes[mediaType] || size < c.minSize {
+		return body
+	}
+
+	// The representation now depends on Accept-Encoding, even when it stays identity
+	addVary(headers, "Accept-Encoding")
+	if r.Method == "HEAD" {
+		return body
+	}
+
+	encoding := c.negotiate(r.Header.Values("Accept-Encoding"))
+	if encoding == "" {
+		return body
+	}
+
+	compressed, err := c.compress(encoding, body)
+	if err != nil || len(compressed) >= len(body) {
+		return body
+	}
+
+	headers.Set("Content-Encoding", encoding)
+	headers.Del("Content-Length")
+
+	// The compressed bytes differ from the backend's, so a strong ETag no longer holds
+	if etag := headers.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		headers.Set("ETag", "W/"+etag)
+	}
+
+	return compressed
+}
+
+func (c *compressor) compress(encoding string, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	switch encoding {
+	case "gzip":
+		w := c.gzipPool.Get().(*gzip.Writer)
+		defer c.gzipPool.Put(w)
+
+		w.Reset(&buf)
+		if _, err := w.Write(body); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+	case "br":
+		w := c.brotliPool.Get().(*brotli.Writer)
+		defer c.brotliPool.Put(w)
+
+		w.Reset(&buf)
+		if _, err := w.Write(body); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+	case "zstd":
+		return c.zstd.EncodeAll(body, nil), nil
+	}
+
+	return buf.Bytes(), nil
+}
+
+// negotiate picks the configured algorithm with the highest client q-value,
+// breaking ties by configured preference. It returns "" for identity.
+func (c *compressor) negotiate(acceptEncoding []string) string {
+	accepted := parseAcceptEncoding(acceptEncoding)
+
+	best, bestQ := "", 0.0
+	for _, algorithm := range c.algorithms {
+		q, ok := accepted[algorithm]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = algorithm, q
+		}
+	}
+
+	return best
+}
+
+// storable reports whether a response to r may be cached under the variant key
+// of the coding negotiated for r: its body must be identity or in that coding.
+// Backends compressing with another coding cannot share that variant.
+func (c *compressor) storable(r *http.Request, headers http.Header) bool {
+	if c == nil {
+		return true
+	}
+
+	encoding := headers.Get("Content-Encoding")
+	return encoding == "" || strings.EqualFold(encoding, "identity") ||
+		strings.EqualFold(encoding, c.negotiate(r.Header.Values("Accept-Encoding")))
+}
+
+// encodings lists the Accept-Encoding values selecting each variant the
+// compressor can produce, identity first
+func (c *compressor) encodings() []string {
+	if c == nil {
+		return []string{""}
+	}
+	return append([]string{""}, c.algorithms...)
+}
+
+// parseAcceptEncoding maps each listed coding to its q-value
+func parseAcceptEncoding(values []string) map[string]float64 {
+	accepted := make(map[string]float64)
+
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding == "" {
+				continue
+			}
+
+			q := 1.0
+			if name, weight, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(weight), 64); err == nil {
+					q = parsed
+				}
+			}
+
+			accepted[coding] = q
+		}
+	}
+
+	return accepted
+}
+
+// normalizeAcceptEncoding reduces an Accept-Encoding header to the sorted set of
+// codings it accepts, so equivalent headers select the same cache variant.
+func normalizeAcceptEncoding(values []string) string {
+	var codings []string
+	for coding, q := range parseAcceptEncoding(values) {
+		if q > 0 && coding != "identity" {
+			codings = append(codings, coding)
+		}
+	}
+
+	sort.Strings(codings)
+	return strings.Join(codings, ",")
+}
+
+// addVary appends name to the Vary header unless it is already listed
+func addVary(headers http.Header, name string) {
+	for _, value := range headers.Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			existing = strings.TrimSpace(existing)
+			if existing == "*" || strings.EqualFold(existing, name) {
+				return
+			}
+		}
+	}
+
+	headers.Add("Vary", name)
+}
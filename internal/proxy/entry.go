@@ -3,9 +3,7 @@ package proxy
 import (
 	"bytes"
 	"encoding/gob"
-	"fmt"
 	"net/http"
-	"strings"
 	"time"
 )
 
@@ -14,10 +12,32 @@ type CachedResponse struct {
 	Headers    http.Header
 	Body       []byte
 	Date       time.Time
+
+	// Vary is set on the marker stored for a primary key when the response
+	// varies on request headers. The response itself lives under the variant
+	// key. Expires records when the marker expires, as it must outlive every
+	// variant it points at.
+	Vary    []string
+	Expires time.Time
+}
+
+// Markers are kept under their own keys and tags, apart from the responses,
+// so purges remove them without counting them as purged responses
+func markerKey(key string) string {
+	return "marker|" + key
+}
+
+func markerTag(tag string) string {
+	return "marker:" + tag
+}
+
+// isVaryMarker reports whether the entry only points at variant keys
+func (c *CachedResponse) isVaryMarker() bool {
+	return c.Vary != nil
 }
 
 // Proxy serializes before storing
-func (p *Proxy) storeResponse(method string, uri string, statusCode int, headers map[string][]string, body []byte, expiresAt time.Time) error {
+func (p *Proxy) storeResponse(r *http.Request, statusCode int, headers http.Header, body []byte, vary []string, ttl time.Duration) error {
 
 	cached := &CachedResponse{
 		StatusCode: statusCode,
@@ -31,61 +51,98 @@ func (p *Proxy) storeResponse(method string, uri string, statusCode int, headers
 		return err
 	}
 
-	key := genKey(method, uri, headers)
+	// Index entries by their URI and by any surrogate keys from the backend
+	tags := []string{p.urlTag("", r.URL.RequestURI())}
+	if p.keys.cfg.IncludeHost {
+		tags = append(tags, p.urlTag(r.Host, r.URL.RequestURI()))
+	}
+	for _, tag := range parseSurrogateKeys(headers) {
+		tags = append(tags, surrogateTag(tag))
+	}
+
+	key := p.keys.Key(r)
+
+	if len(vary) > 0 {
+		if err := p.storeMarker(key, vary, tags, ttl); err != nil {
+			return err
+		}
+
+		// A response stored before the resource started to vary would
+		// otherwise still be served to every request
+		p.cache.Delete(key)
 
-	ttl := time.Until(expiresAt)
+		key = p.keys.VariantKey(key, vary, r)
+	}
 
 	p.cache.Set(key, value, ttl)
+	p.cache.Tag(key, tags...)
 
 	return nil
 }
 
-func (p *Proxy) getResponse(method string, uri string, headers http.Header) (*CachedResponse, bool) {
+// storeMarker records which request headers select the variant of the
+// response at key. Variants may have different TTLs, so the marker's TTL is
+// only ever extended.
+func (p *Proxy) storeMarker(key string, vary, tags []string, ttl time.Duration) error {
+	key = markerKey(key)
 
-	key := genKey(method, uri, headers)
-
-	value, found := p.cache.Get(key)
-	if !found {
-		return nil, false
+	if existing, found := p.lookup(key); found && existing.isVaryMarker() {
+		ttl = max(ttl, time.Until(existing.Expires))
 	}
 
-	cached, err := deserialize(value)
+	now := time.Now()
+	marker, err := serialize(&CachedResponse{Vary: vary, Date: now, Expires: now.Add(ttl)})
 	if err != nil {
-		return nil, false
+		return err
 	}
 
-	return cached, true
-}
+	markerTags := make([]string, len(tags))
+	for i, tag := range tags {
+		markerTags[i] = markerTag(tag)
+	}
 
-// genKey generates a unique key for a given request.
-func genKey(method string, uri string, headers http.Header) string {
+	p.cache.Set(key, marker, ttl)
+	p.cache.Tag(key, markerTags...)
 
-	// Define base resource key
-	key := fmt.Sprintf("%s|%s", method, uri)
+	return nil
+}
 
-	// Read `Vary` from response headers.
-	vary := headers.Get("Vary")
+func (p *Proxy) getResponse(r *http.Request) (*CachedResponse, bool) {
 
-	// If absent, treat as empty (no variants).
-	if vary != "" {
-		names := strings.Split(vary, ",")
-		values := make([]string, len(names))
+	key := p.keys.Key(r)
 
-		for i, name := range names {
-			// Parse header names in `Vary` -> normalize (lowercase, trim).
-			trimmed := strings.TrimSpace(strings.ToLower(name))
-			// For each header name in `Vary`, obtain the request’s header value(s). Normalize and join them.
-			values[i] = strings.Join(headers.Values(trimmed), ",")
+	// Responses that vary are only reachable through their marker
+	cached, found := p.lookup(key)
+	if !found {
+		cached, found = p.lookup(markerKey(key))
+	}
+	if !found {
+		return nil, false
+	}
+
+	// Follow the marker to the variant matching this request's headers
+	if cached.isVaryMarker() {
+		cached, found = p.lookup(p.keys.VariantKey(key, cached.Vary, r))
+		if !found || cached.isVaryMarker() {
+			return nil, false
 		}
+	}
 
-		// Build variant key
-		variantKey := fmt.Sprintf("|vary:%s", strings.Join(values, ","))
+	return cached, true
+}
 
-		// Full cache key = base key + variant key.
-		key = fmt.Sprintf("%s%s", key, variantKey)
+func (p *Proxy) lookup(key string) (*CachedResponse, bool) {
+	value, found := p.cache.Get(key)
+	if !found {
+		return nil, false
 	}
 
-	return key
+	cached, err := deserialize(value)
+	if err != nil {
+		return nil, false
+	}
+
+	return cached, true
 }
 
 // Proxy serializes before storing
@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+)
+
+// Fraction of the time since Last-Modified used as heuristic freshness (RFC 9111 section 4.2.2)
+const heuristicFraction = 0.1
+
+// Status codes that may be cached with heuristic freshness (RFC 9110 section 15.1),
+// except 206 which is never stored
+var heuristicCodes = map[int]bool{
+	200: true,
+	203: true,
+	204: true,
+	300: true,
+	301: true,
+	308: true,
+	404: true,
+	405: true,
+	410: true,
+	414: true,
+	501: true,
+}
+
+// ttlLimits bounds the freshness lifetime of a stored response
+type ttlLimits struct {
+	defaultTTL time.Duration
+	maxAge     time.Duration
+}
+
+// limitsFor returns the limits for path, using the longest matching route override
+func (p *Proxy) limitsFor(path string) ttlLimits {
+	limits := ttlLimits{defaultTTL: p.defaultTTL, maxAge: p.maxAge}
+
+	var matched *config.RouteConfig
+	for i := range p.routes {
+		route := &p.routes[i]
+		if strings.HasPrefix(path, route.PathPrefix) &&
+			(matched == nil || len(route.PathPrefix) > len(matched.PathPrefix)) {
+			matched = route
+		}
+	}
+
+	if matched != nil {
+		limits = ttlLimits{defaultTTL: matched.DefaultTTL, maxAge: matched.MaxAge}
+	}
+
+	return limits
+}
+
+// freshnessLifetime computes how long a response stays fresh from now. It returns
+// false when the response carries no explicit freshness and may not be cached
+// heuristically.
+func freshnessLifetime(statusCode int, headers http.Header, directives map[string]string, limits ttlLimits, now time.Time) (time.Duration, bool) {
+
+	date := now
+	if parsed, err := http.ParseTime(headers.Get("Date")); err == nil {
+		date = parsed
+	}
+
+	// Time the response already spent in upstream caches
+	var age time.Duration
+	if seconds, err := strconv.Atoi(headers.Get("Age")); err == nil && seconds > 0 {
+		age = time.Duration(seconds) * time.Second
+	}
+
+	// Explicit freshness, in order of precedence for a shared cache
+	lifetime, explicit := explicitLifetime(headers, directives, date)
+
+	if !explicit {
+		// Heuristic freshness is only allowed for codes cacheable by default or public responses
+		if _, public := directives["public"]; !heuristicCodes[statusCode] && !public {
+			return 0, false
+		}
+
+		lifetime = limits.defaultTTL
+		if lastModified, err := http.ParseTime(headers.Get("Last-Modified")); err == nil && lastModified.Before(date) {
+			lifetime = time.Duration(float64(date.Sub(lastModified)) * heuristicFraction)
+		}
+	}
+
+	lifetime = min(lifetime, limits.maxAge)
+
+	return lifetime - age, true
+}
+
+// explicitLifetime reads s-maxage, max-age or Expires, whichever takes precedence
+func explicitLifetime(headers http.Header, directives map[string]string, date time.Time) (time.Duration, bool) {
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if value, ok := directives[directive]; ok {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds < 0 {
+				// Invalid values make the response stale (RFC 9111 section 4.2.1)
+				return 0, true
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+
+	if expires := headers.Get("Expires"); expires != "" {
+		parsed, err := http.ParseTime(expires)
+		if err != nil {
+			// Invalid dates such as "0" mean already expired
+			return 0, true
+		}
+		return parsed.Sub(date), true
+	}
+
+	return 0, false
+}
+
+// parseCacheControl splits a Cache-Control header into lowercase directive names
+// mapped to their unquoted values.
+func parseCacheControl(header string) map[string]string {
+	directives := make(map[string]string)
+
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, value, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+
+	return directives
+}
@@ -1,9 +1,12 @@
 package proxy
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/Lucascluz/reverxy/internal/proxy/middleware"
 )
@@ -11,14 +14,20 @@ import (
 // Implement http.Handler interface directly
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
+	// Purge requests are answered by the proxy itself
+	if r.Method == "PURGE" {
+		p.servePurge(w, r)
+		return
+	}
+
 	// Try to serve from cache
 	if p.cache != nil {
 		hit, cached := p.tryServingCachedResponse(r)
 
 		if hit {
-			// Write response body to client
-			w.WriteHeader(cached.StatusCode)
-			w.Write(cached.Body)
+			// Write stored response to client, answering Range requests from the full body
+			copyHeader(w.Header(), cached.Headers)
+			serveBody(w, r, cached.StatusCode, cached.Body)
 			// Notify middleware of cache decision
 			if cw, ok := w.(middleware.CacheDecisionWriter); ok {
 				cw.SetCacheDecision("HIT", "", r.RequestURI)
@@ -33,15 +42,20 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get next backend from load balancer
-	backend, err := p.loadBalancer.Next()
+	// Get next backend from load balancer, possibly after waiting in its queue
+	// The backend comes with a connection slot taken for this request
+	backend, err := p.loadBalancer.Next(r)
 	if err != nil {
 		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
 		return
 	}
+	defer backend.DecrementConnections()
+
+	// Keep the client on this backend for later requests (sticky sessions)
+	p.loadBalancer.Bind(w, r, backend)
 
 	// Create new request with backend URL and original request details
-	outReq, err := http.NewRequest(r.Method, backend.Url()+r.URL.Path, r.Body)
+	outReq, err := http.NewRequestWithContext(r.Context(), r.Method, backend.Url()+r.URL.RequestURI(), r.Body)
 	if err != nil {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
@@ -50,12 +64,20 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Copy headers but STRIP hop-by-hop headers
 	copyHeader(outReq.Header, r.Header)
 
-	// Increment backend connection count
-	backend.IncrementConnections()
-	defer backend.DecrementConnections()
+	// Fetch the full object for range requests whose response may be stored;
+	// the range is cut locally so a 206 fragment is never stored under the
+	// full-object key. Other range requests go to the backend as they are.
+	if p.mayStore(r) {
+		outReq.Header.Del("Range")
+		outReq.Header.Del("If-Range")
+	}
+
+	// Failed requests are timed too, so a backend timing out scores as slow
+	start := time.Now()
 
 	resp, err := p.client.Do(outReq)
 	if err != nil {
+		backend.RecordLatency(time.Since(start))
 		http.Error(w, "Bad Gateway", http.StatusBadGateway)
 		return
 	}
@@ -63,19 +85,31 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Read response body (needed for caching)
 	body, err := io.ReadAll(resp.Body)
+	backend.RecordLatency(time.Since(start))
 	if err != nil {
 		http.Error(w, "Error reading backend response", http.StatusBadGateway)
 		return
 	}
 
+	// Compress eligible responses; the compressed variant is what gets cached
+	headers := resp.Header.Clone()
+	body = p.compressor.apply(r, resp.StatusCode, headers, body)
+
 	// Copy response headers (stripping hop-by-hop again)
-	copyHeader(w.Header(), resp.Header)
+	copyHeader(w.Header(), headers)
 
-	w.WriteHeader(resp.StatusCode)
-	w.Write(body)
+	serveBody(w, r, resp.StatusCode, body)
 
 	if p.cache != nil {
-		cached, reason := p.tryCachingResponse(r, resp.StatusCode, resp.Header, body)
+		// Successful writes make stored responses for the affected URIs stale
+		if invalidated := p.invalidateAfterUnsafe(r, resp.StatusCode, headers); invalidated > 0 {
+			if cw, ok := w.(middleware.CacheDecisionWriter); ok {
+				cw.SetCacheDecision("INVALIDATE", fmt.Sprintf("%d entries", invalidated), r.RequestURI)
+			}
+			return
+		}
+
+		cached, reason := p.tryCachingResponse(r, resp.StatusCode, headers, body)
 
 		// Notify middleware of cache decision
 		if cw, ok := w.(middleware.CacheDecisionWriter); ok {
@@ -88,6 +122,24 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// serveBody writes a complete response. Full 200 responses to GET requests
+// with a Range header go through http.ServeContent, which cuts the requested
+// ranges (including multipart byte ranges) from the stored body. Everything
+// else, HEAD included, is written as is with the backend's Content-Length.
+func serveBody(w http.ResponseWriter, r *http.Request, statusCode int, body []byte) {
+	if statusCode != http.StatusOK || r.Method != "GET" || r.Header.Get("Range") == "" {
+		w.WriteHeader(statusCode)
+		w.Write(body)
+		return
+	}
+
+	// ServeContent computes the length of whatever part it sends
+	w.Header().Del("Content-Length")
+
+	lastModified, _ := http.ParseTime(w.Header().Get("Last-Modified"))
+	http.ServeContent(w, r, "", lastModified, bytes.NewReader(body))
+}
+
 // Helper to copy headers while skipping hop-by-hop ones
 func copyHeader(dst, src http.Header) {
 	for k, vv := range src {
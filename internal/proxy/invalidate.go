@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// isSafeMethod reports whether method is safe as defined by RFC 9110 section 9.2.1
+func isSafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
+}
+
+// invalidateAfterUnsafe implements RFC 9111 section 4.4: a non-error response to
+// an unsafe request invalidates the stored responses for the target URI and for
+// the URIs in Location and Content-Location, as long as those share its origin.
+// It returns the number of cache entries removed.
+func (p *Proxy) invalidateAfterUnsafe(r *http.Request, statusCode int, headers http.Header) int {
+	if isSafeMethod(r.Method) {
+		return 0
+	}
+
+	// Only 2xx and 3xx responses invalidate
+	if statusCode < 200 || statusCode >= 400 {
+		return 0
+	}
+
+	invalidated := p.PurgeURL(r.Host, r.URL.RequestURI())
+
+	for _, header := range []string{"Location", "Content-Location"} {
+		if uri, ok := sameOriginURI(r, headers.Get(header)); ok {
+			invalidated += p.PurgeURL(r.Host, uri)
+		}
+	}
+
+	return invalidated
+}
+
+// sameOriginURI resolves ref against the request URL and returns its request URI
+// when it points at the same host the request was sent to.
+func sameOriginURI(r *http.Request, ref string) (string, bool) {
+	if ref == "" {
+		return "", false
+	}
+
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return "", false
+	}
+
+	// Relative references always share the request's origin
+	if parsed.Host != "" && parsed.Host != r.Host {
+		return "", false
+	}
+
+	return r.URL.ResolveReference(parsed).RequestURI(), true
+}
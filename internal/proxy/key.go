@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+)
+
+// keyBuilder composes cache keys from the parts of a request selected by the
+// cache_key configuration. Keys have the form
+//
+//	METHOD|/path?query[|host=...][|scheme=...][|h:name=value...][|c:name=value...]
+//
+// so every key for a resource starts with METHOD|/path, which prefix purges rely on.
+// Vary markers are stored apart, under the key prefixed with "marker|".
+type keyBuilder struct {
+	cfg        config.CacheKeyConfig
+	headers    []string
+	compressor *compressor
+}
+
+func newKeyBuilder(cfg config.CacheKeyConfig, compressor *compressor) *keyBuilder {
+	headers := make([]string, len(cfg.Headers))
+	for i, name := range cfg.Headers {
+		headers[i] = http.CanonicalHeaderKey(strings.TrimSpace(name))
+	}
+	sort.Strings(headers)
+
+	cookies := append([]string(nil), cfg.Cookies...)
+	sort.Strings(cookies)
+	cfg.Cookies = cookies
+
+	// Parameter names are compared after lowercasing when case is ignored
+	if cfg.IgnoreCase {
+		cfg.IncludeQuery = lowerAll(cfg.IncludeQuery)
+		cfg.ExcludeQuery = lowerAll(cfg.ExcludeQuery)
+	}
+
+	return &keyBuilder{cfg: cfg, headers: headers, compressor: compressor}
+}
+
+// Key returns the primary cache key for r
+func (k *keyBuilder) Key(r *http.Request) string {
+	var b strings.Builder
+
+	b.WriteString(r.Method)
+	b.WriteString("|")
+	b.WriteString(k.NormalizeURI(r.URL.RequestURI()))
+
+	if k.cfg.IncludeHost {
+		b.WriteString("|host=")
+		b.WriteString(strings.ToLower(r.Host))
+	}
+
+	if k.cfg.IncludeScheme {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		b.WriteString("|scheme=")
+		b.WriteString(scheme)
+	}
+
+	for _, name := range k.headers {
+		b.WriteString("|h:")
+		b.WriteString(strings.ToLower(name))
+		b.WriteString("=")
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+
+	for _, name := range k.cfg.Cookies {
+		value := ""
+		if cookie, err := r.Cookie(name); err == nil {
+			value = cookie.Value
+		}
+		b.WriteString("|c:")
+		b.WriteString(name)
+		b.WriteString("=")
+		b.WriteString(value)
+	}
+
+	return b.String()
+}
+
+// VariantKey extends a primary key with the request values of the headers a
+// response listed in Vary. When the proxy compresses, Accept-Encoding is reduced
+// to the coding it negotiates, since that alone selects the stored body.
+func (k *keyBuilder) VariantKey(key string, vary []string, r *http.Request) string {
+	values := make([]string, len(vary))
+	for i, name := range vary {
+		value := strings.Join(r.Header.Values(name), ",")
+		if name == "Accept-Encoding" {
+			if k.compressor != nil {
+				value = k.compressor.negotiate(r.Header.Values(name))
+			} else {
+				value = normalizeAcceptEncoding(r.Header.Values(name))
+			}
+		}
+		values[i] = name + "=" + value
+	}
+
+	return key + "|vary:" + strings.Join(values, ";")
+}
+
+// NormalizeURI applies the query and case rules to a request URI, so requests
+// that differ only in ignored parts share one cache entry.
+func (k *keyBuilder) NormalizeURI(uri string) string {
+	path, rawQuery, _ := strings.Cut(uri, "?")
+
+	if k.cfg.IgnoreCase {
+		path = strings.ToLower(path)
+	}
+
+	query := k.normalizeQuery(rawQuery)
+	if query == "" {
+		return path
+	}
+	return path + "?" + query
+}
+
+func (k *keyBuilder) normalizeQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	// Keep the raw query untouched unless a rule needs to inspect it
+	if len(k.cfg.IncludeQuery) == 0 && len(k.cfg.ExcludeQuery) == 0 && !k.cfg.SortQuery && !k.cfg.IgnoreCase {
+		return rawQuery
+	}
+
+	params := strings.Split(rawQuery, "&")
+	kept := params[:0]
+
+	for _, param := range params {
+		if param == "" {
+			continue
+		}
+
+		if k.cfg.IgnoreCase {
+			param = strings.ToLower(param)
+		}
+
+		name, _, _ := strings.Cut(param, "=")
+		if decoded, err := url.QueryUnescape(name); err == nil {
+			name = decoded
+		}
+
+		if len(k.cfg.IncludeQuery) > 0 && !matchAny(k.cfg.IncludeQuery, name) {
+			continue
+		}
+		if matchAny(k.cfg.ExcludeQuery, name) {
+			continue
+		}
+
+		kept = append(kept, param)
+	}
+
+	if k.cfg.SortQuery {
+		sort.Strings(kept)
+	}
+
+	return strings.Join(kept, "&")
+}
+
+// matchAny reports whether name matches any pattern; a trailing "*" matches a prefix
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(value)
+	}
+	return lowered
+}
+
+// parseVary returns the canonical header names listed in a Vary header, and
+// false when the response varies on "*" and can never be matched.
+func parseVary(headers http.Header) ([]string, bool) {
+	var names []string
+
+	for _, value := range headers.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name == "*" {
+				return nil, false
+			}
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+
+	sort.Strings(names)
+	return names, true
+}
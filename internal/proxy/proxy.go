@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"fmt"
 	"net"
 	"net/http"
 	"time"
@@ -8,6 +9,7 @@ import (
 	"github.com/Lucascluz/reverxy/internal/cache"
 	"github.com/Lucascluz/reverxy/internal/config"
 	"github.com/Lucascluz/reverxy/internal/loadbalancer"
+	"github.com/Lucascluz/reverxy/internal/ratelimiter"
 )
 
 type Proxy struct {
@@ -15,15 +17,32 @@ type Proxy struct {
 	Port      string
 	ProbePort string
 
-	defaultTTL time.Duration
-	maxAge     time.Duration
-	client     *http.Client
+	defaultTTL   time.Duration
+	maxAge       time.Duration
+	routes       []config.RouteConfig
+	adminToken   string
+	snapshotPath string
+	client       *http.Client
 
 	loadBalancer *loadbalancer.LoadBalancer
 	cache        cache.Cache
+	keys         *keyBuilder
+	compressor   *compressor
+	extractor    *ratelimiter.Extractor
 }
 
-func New(cfg *config.Config) *Proxy {
+func New(cfg *config.Config) (*Proxy, error) {
+
+	// Client IPs are resolved behind trusted proxies for rate limiting and hashing
+	extractor, err := ratelimiter.NewExtractor(cfg.RateLimiter.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create IP extractor: %w", err)
+	}
+
+	loadBalancer, err := loadbalancer.NewLoadBalancer(&cfg.LoadBalancer, extractor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create load balancer: %w", err)
+	}
 
 	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
@@ -38,14 +57,19 @@ func New(cfg *config.Config) *Proxy {
 		ExpectContinueTimeout: 1 * time.Second,
 	}
 
+	compressor := newCompressor(cfg.Compression)
+
 	return &Proxy{
 
 		Host:      cfg.Proxy.Host,
 		Port:      cfg.Proxy.Port,
 		ProbePort: cfg.Proxy.ProbePort,
 
-		defaultTTL: time.Duration(cfg.Proxy.DefaultTTL) * time.Second,
-		maxAge:     time.Duration(cfg.Proxy.MaxAge) * time.Second,
+		defaultTTL:   cfg.Proxy.DefaultTTL,
+		maxAge:       cfg.Proxy.MaxAge,
+		routes:       cfg.Proxy.Routes,
+		adminToken:   cfg.Admin.Token,
+		snapshotPath: cfg.Cache.SnapshotPath,
 
 		client: &http.Client{
 			Transport: transport,
@@ -55,9 +79,12 @@ func New(cfg *config.Config) *Proxy {
 			},
 		},
 
-		loadBalancer: loadbalancer.NewLoadBalancer(&cfg.LoadBalancer),
+		loadBalancer: loadBalancer,
 		cache:        cache.NewCache(&cfg.Cache),
-	}
+		keys:         newKeyBuilder(cfg.Cache.Key, compressor),
+		compressor:   compressor,
+		extractor:    extractor,
+	}, nil
 }
 
 func (p *Proxy) IsReady() bool {
@@ -72,3 +99,32 @@ func (p *Proxy) SetReady(ready bool) {
 func (p *Proxy) LoadBalancer() *loadbalancer.LoadBalancer {
 	return p.loadBalancer
 }
+
+// RestoreCache loads the cache snapshot written by Close, if one is configured.
+// It returns the number of entries restored.
+func (p *Proxy) RestoreCache() (int, error) {
+	if p.cache == nil || p.snapshotPath == "" {
+		return 0, nil
+	}
+	return cache.LoadSnapshot(p.cache, p.snapshotPath)
+}
+
+// Close snapshots the cache when configured, then stops it. It returns the
+// number of entries written to the snapshot.
+func (p *Proxy) Close() (int, error) {
+	if p.cache == nil {
+		return 0, nil
+	}
+
+	var saved int
+	var err error
+	if p.snapshotPath != "" {
+		saved, err = cache.SaveSnapshot(p.cache, p.snapshotPath)
+	}
+
+	if stopErr := p.cache.Stop(); err == nil {
+		err = stopErr
+	}
+
+	return saved, err
+}
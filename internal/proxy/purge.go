@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Lucascluz/reverxy/internal/cache"
+)
+
+// Methods whose cache entries are addressed by purge requests
+var purgeMethods = []string{"GET", "HEAD"}
+
+// PurgeURL removes every cached variant of the given request URI. When cache
+// keys include the host, only the variants cached for host are removed; an
+// empty host removes those of every host.
+func (p *Proxy) PurgeURL(host, uri string) int {
+	if p.cache == nil {
+		return 0
+	}
+
+	tag := p.urlTag(host, uri)
+	p.cache.PurgeTag(markerTag(tag))
+
+	return p.cache.PurgeTag(tag)
+}
+
+// PurgePrefix removes every cached response whose request URI starts with
+// prefix. It fails when the cache cannot purge by prefix or the purge stopped
+// early, returning how many entries were removed before that.
+func (p *Proxy) PurgePrefix(prefix string) (int, error) {
+	if p.cache == nil {
+		return 0, nil
+	}
+
+	if p.keys.cfg.IgnoreCase {
+		prefix = strings.ToLower(prefix)
+	}
+
+	purged := 0
+	for _, method := range purgeMethods {
+		if _, err := p.cache.PurgePrefix(markerKey(method + "|" + prefix)); err != nil {
+			return purged, err
+		}
+
+		n, err := p.cache.PurgePrefix(method + "|" + prefix)
+		purged += n
+		if err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+// PurgeTags removes every cached response tagged with any of the given surrogate keys
+func (p *Proxy) PurgeTags(tags ...string) int {
+	if p.cache == nil {
+		return 0
+	}
+
+	purged := 0
+	for _, tag := range tags {
+		p.cache.PurgeTag(markerTag(surrogateTag(tag)))
+		purged += p.cache.PurgeTag(surrogateTag(tag))
+	}
+	return purged
+}
+
+// Purge removes cached responses matching uri. A trailing "*" purges by prefix
+// on every host, anything else purges the exact URI on host as PurgeURL does.
+func (p *Proxy) Purge(host, uri string) (int, error) {
+	if prefix, ok := strings.CutSuffix(uri, "*"); ok {
+		return p.PurgePrefix(prefix)
+	}
+	return p.PurgeURL(host, uri), nil
+}
+
+// servePurge handles the HTTP PURGE method. The request URI selects the entries
+// to purge and an optional Surrogate-Key header purges by tag instead.
+func (p *Proxy) servePurge(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r, p.adminToken) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var purged int
+	if tags := parseSurrogateKeys(r.Header); len(tags) > 0 {
+		purged = p.PurgeTags(tags...)
+	} else {
+		var err error
+		if purged, err = p.Purge(r.Host, r.URL.RequestURI()); err != nil {
+			purgeError(w, purged, err)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int{"purged": purged})
+}
+
+// purgeError answers a purge that could not run or did not finish: 501 when the
+// cache cannot purge by prefix, 500 otherwise, with the entries already purged
+func purgeError(w http.ResponseWriter, purged int, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, cache.ErrPrefixPurgeUnsupported) {
+		status = http.StatusNotImplemented
+	}
+
+	http.Error(w, fmt.Sprintf("%v (%d entries purged)", err, purged), status)
+}
+
+// parseSurrogateKeys collects tags from Surrogate-Key (space separated) and
+// Cache-Tag (comma separated) headers.
+func parseSurrogateKeys(headers http.Header) []string {
+	var tags []string
+
+	for _, value := range headers.Values("Surrogate-Key") {
+		tags = append(tags, strings.Fields(value)...)
+	}
+
+	for _, value := range headers.Values("Cache-Tag") {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	return tags
+}
+
+// urlTag is the implicit tag shared by every cached variant of a request URI.
+// When cache keys include the host the tag names it too, and entries carry
+// both the tag of their host and the one of every host (an empty host).
+func (p *Proxy) urlTag(host, uri string) string {
+	tag := "url:" + p.keys.NormalizeURI(uri)
+	if p.keys.cfg.IncludeHost && host != "" {
+		tag += "|host=" + strings.ToLower(host)
+	}
+	return tag
+}
+
+// surrogateTag namespaces backend-provided tags so they cannot collide with urlTag
+func surrogateTag(tag string) string {
+	return "key:" + tag
+}
+
+// authorized reports whether r carries the admin bearer token. An empty token
+// disables every admin operation.
+func authorized(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
@@ -12,8 +12,9 @@ import (
 
 // Setup encapsulates the complete proxy initialization
 type Setup struct {
-	proxy *Proxy
-	cfg   *config.Config
+	proxy  *Proxy
+	warmer *Warmer
+	cfg    *config.Config
 }
 
 // NewSetup creates a proxy with its configuration ready for handler building
@@ -22,11 +23,15 @@ func NewSetup(cfg *config.Config) (*Setup, error) {
 		return nil, fmt.Errorf("config cannot be nil")
 	}
 
-	p := New(cfg)
+	p, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Setup{
-		proxy: p,
-		cfg:   cfg,
+		proxy:  p,
+		warmer: NewWarmer(p, cfg.Cache.Warmup),
+		cfg:    cfg,
 	}, nil
 }
 
@@ -35,6 +40,11 @@ func (s *Setup) Proxy() *Proxy {
 	return s.proxy
 }
 
+// Warmer returns the cache warmer bound to the proxy
+func (s *Setup) Warmer() *Warmer {
+	return s.warmer
+}
+
 // Builds and returns the complete middleware-wrapped handler
 func (s *Setup) Handler() (http.Handler, error) {
 
@@ -44,17 +54,11 @@ func (s *Setup) Handler() (http.Handler, error) {
 	// Create rate limiter
 	limiter := ratelimiter.New(s.cfg.RateLimiter)
 
-	// Create IP extractor
-	extractor, err := ratelimiter.NewExtractor(s.cfg.RateLimiter.TrustedProxies)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create IP extractor: %w", err)
-	}
-
 	// Build middleware chain from innermost to outermost
 	handler := http.Handler(s.proxy)
 
 	// Apply rate limiting first (rejects early)
-	handler = middleware.RateLimiting(limiter, extractor, handler)
+	handler = middleware.RateLimiting(limiter, s.proxy.extractor, handler)
 
 	// Apply logging last (wraps everything)
 	handler = middleware.Logging(log, handler)
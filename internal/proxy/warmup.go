@@ -0,0 +1,386 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Lucascluz/reverxy/internal/config"
+	"github.com/Lucascluz/reverxy/internal/observability"
+)
+
+const (
+	// Maximum number of failures kept in a warm-up report
+	maxReportedFailures = 100
+
+	// How long a startup warm-up waits for a healthy backend
+	warmupReadyTimeout = time.Minute
+)
+
+// WarmupReport describes the progress of the current or last warm-up run
+type WarmupReport struct {
+	Running    bool      `json:"running"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	Total      int       `json:"total"`
+	Done       int       `json:"done"`
+	Stored     int       `json:"stored"`
+	Cached     int       `json:"already_cached"`
+	Uncached   int       `json:"not_cacheable"`
+	Failed     int       `json:"failed"`
+	Failures   []string  `json:"failures,omitempty"`
+}
+
+// Warmer populates the cache by replaying GET requests through the proxy
+type Warmer struct {
+	proxy *Proxy
+	cfg   config.WarmupConfig
+	log   *observability.Logger
+
+	mu     sync.Mutex
+	report WarmupReport
+	cancel context.CancelFunc
+}
+
+func NewWarmer(p *Proxy, cfg config.WarmupConfig) *Warmer {
+	return &Warmer{
+		proxy: p,
+		cfg:   cfg,
+		log:   observability.NewLogger("warmup"),
+	}
+}
+
+// Status returns a snapshot of the current or last run
+func (w *Warmer) Status() WarmupReport {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	report := w.report
+	report.Failures = append([]string(nil), w.report.Failures...)
+	return report
+}
+
+// Start launches a warm-up of urls in the background. It fails if a run is
+// already in progress.
+func (w *Warmer) Start(urls []string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.report.Running {
+		return fmt.Errorf("warm-up already running")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.report = WarmupReport{
+		Running:   true,
+		StartedAt: time.Now(),
+		Total:     len(urls),
+	}
+
+	go w.run(ctx, urls)
+
+	return nil
+}
+
+// StartOnReady waits until the load balancer has a healthy backend, then warms
+// the configured URL sources. Used for the startup warm-up.
+func (w *Warmer) StartOnReady() {
+	deadline := time.Now().Add(warmupReadyTimeout)
+	for !w.proxy.IsReady() {
+		if time.Now().After(deadline) {
+			w.log.Errorf("no healthy backend after %s, skipping startup warm-up", warmupReadyTimeout)
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	urls, err := w.LoadURLs()
+	if err != nil {
+		w.log.Errorf("failed to load warm-up URLs: %v", err)
+		return
+	}
+
+	if err := w.Start(urls); err != nil {
+		w.log.Errorf("failed to start warm-up: %v", err)
+	}
+}
+
+// Stop cancels a run in progress
+func (w *Warmer) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cancel != nil {
+		w.cancel()
+	}
+}
+
+func (w *Warmer) run(ctx context.Context, urls []string) {
+	w.log.Infof("warming cache with %d URLs (concurrency=%d)", len(urls), w.cfg.Concurrency)
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+
+	for range w.cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for uri := range jobs {
+				w.record(uri, w.fetch(ctx, uri))
+			}
+		}()
+	}
+
+	for _, uri := range urls {
+		select {
+		case jobs <- uri:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.report.Running = false
+	w.report.FinishedAt = time.Now()
+	w.log.Infof("finished in %s: %d/%d done, stored=%d already_cached=%d not_cacheable=%d failed=%d",
+		w.report.FinishedAt.Sub(w.report.StartedAt).Round(time.Millisecond),
+		w.report.Done, w.report.Total, w.report.Stored, w.report.Cached, w.report.Uncached, w.report.Failed)
+}
+
+// fetch warms every encoding variant of uri the proxy can serve, identity and
+// one per compression algorithm, and returns the combined outcome: the first
+// failure, or STORE when any variant was stored.
+func (w *Warmer) fetch(ctx context.Context, uri string) warmupResult {
+	var result warmupResult
+
+	for _, encoding := range w.proxy.compressor.encodings() {
+		variant := w.fetchVariant(ctx, uri, encoding)
+		if variant.err != nil {
+			return variant
+		}
+		if result.decision != "STORE" {
+			result.decision = variant.decision
+		}
+	}
+
+	return result
+}
+
+// fetchVariant sends one GET through the proxy handler and returns its outcome
+func (w *Warmer) fetchVariant(ctx context.Context, uri, acceptEncoding string) warmupResult {
+	ctx, cancel := context.WithTimeout(ctx, w.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return warmupResult{err: err}
+	}
+	req.RequestURI = req.URL.RequestURI()
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	rec := &warmupRecorder{header: make(http.Header)}
+	w.proxy.ServeHTTP(rec, req)
+
+	if rec.status >= 400 {
+		return warmupResult{err: fmt.Errorf("status %d", rec.status)}
+	}
+
+	return warmupResult{decision: rec.cacheStatus}
+}
+
+func (w *Warmer) record(uri string, result warmupResult) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.report.Done++
+
+	switch {
+	case result.err != nil:
+		w.report.Failed++
+		if len(w.report.Failures) < maxReportedFailures {
+			w.report.Failures = append(w.report.Failures, fmt.Sprintf("%s: %v", uri, result.err))
+		}
+	case result.decision == "STORE":
+		w.report.Stored++
+	case result.decision == "HIT":
+		w.report.Cached++
+	default:
+		w.report.Uncached++
+	}
+
+	// Log progress roughly every 10%
+	step := max(w.report.Total/10, 1)
+	if w.report.Done%step == 0 && w.report.Done < w.report.Total {
+		w.log.Infof("progress %d/%d (failed=%d)", w.report.Done, w.report.Total, w.report.Failed)
+	}
+}
+
+// LoadURLs reads the configured URL list file and sitemap
+func (w *Warmer) LoadURLs() ([]string, error) {
+	var urls []string
+
+	if w.cfg.URLsFile != "" {
+		fileURLs, err := readURLList(w.cfg.URLsFile)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, fileURLs...)
+	}
+
+	if w.cfg.Sitemap != "" {
+		sitemapURLs, err := readSitemap(w.cfg.Sitemap, 0)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, sitemapURLs...)
+	}
+
+	return urls, nil
+}
+
+type warmupResult struct {
+	decision string
+	err      error
+}
+
+// warmupRecorder discards the response while keeping what the report needs
+type warmupRecorder struct {
+	header      http.Header
+	status      int
+	cacheStatus string
+}
+
+func (r *warmupRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *warmupRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (r *warmupRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+// SetCacheDecision implements middleware.CacheDecisionWriter
+func (r *warmupRecorder) SetCacheDecision(status, reason, backend string) {
+	r.cacheStatus = status
+}
+
+// readURLList parses one URL per line, ignoring blank lines and # comments
+func readURLList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening URL list: %w", err)
+	}
+	defer file.Close()
+
+	return parseURLList(file)
+}
+
+func parseURLList(r io.Reader) ([]string, error) {
+	var urls []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+
+	return urls, scanner.Err()
+}
+
+// Maximum depth of nested sitemap indexes
+const maxSitemapDepth = 3
+
+type sitemapDocument struct {
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// readSitemap parses a sitemap or sitemap index from a file or http(s) URL,
+// following nested sitemaps.
+func readSitemap(location string, depth int) ([]string, error) {
+	if depth > maxSitemapDepth {
+		return nil, fmt.Errorf("sitemap %s nested too deeply", location)
+	}
+
+	body, err := openLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var doc sitemapDocument
+	if err := xml.NewDecoder(body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid sitemap %s: %w", location, err)
+	}
+
+	urls := make([]string, 0, len(doc.URLs))
+	for _, u := range doc.URLs {
+		if loc := strings.TrimSpace(u.Loc); loc != "" {
+			urls = append(urls, loc)
+		}
+	}
+
+	for _, nested := range doc.Sitemaps {
+		nestedURLs, err := readSitemap(strings.TrimSpace(nested.Loc), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, nestedURLs...)
+	}
+
+	return urls, nil
+}
+
+func openLocation(location string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		file, err := os.Open(location)
+		if err != nil {
+			return nil, fmt.Errorf("error opening sitemap: %w", err)
+		}
+		return file, nil
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching sitemap: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error fetching sitemap %s: status %d", location, resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}